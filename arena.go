@@ -0,0 +1,205 @@
+package jsonconv
+
+// chunk sizes of an Arena, in values
+const (
+	minArenaChunk = 64
+	maxArenaChunk = 4096
+)
+
+// Arena parses documents into values allocated from memory which is reused once Release
+// is called, so that parsing many small documents creates few heap objects. Strings
+// which need no unescaping share the memory of a single copy of the input.
+//
+// All values parsed by an Arena become invalid when Release is called, including values
+// reached from them and values copied into other documents with Set or Append. An Arena
+// must not be used by several goroutines at once; keep one per goroutine, or in a
+// sync.Pool.
+type Arena struct {
+	nodes    [][]JsonValue
+	node     int // current chunk in nodes
+	nodeUsed int
+
+	slabs    [][]*JsonValue // children of arrays
+	slab     int
+	slabUsed int
+
+	maps    []map[string]*JsonValue
+	mapUsed int
+
+	keySlabs    [][]string // keys of objects, in order
+	keySlab     int
+	keySlabUsed int
+
+	// children of the arrays and keys of the objects being parsed
+	stack    []*JsonValue
+	keyStack []string
+}
+
+func NewArena() *Arena {
+	return &Arena{}
+}
+
+// Parse works like NewFromBytes, allocating from the arena
+func (a *Arena) Parse(b []byte, opts ...ParseOptions) (*JsonValue, error) {
+	return a.parse(b, string(b), opts)
+}
+
+// ParseString works like NewFromString, allocating from the arena
+func (a *Arena) ParseString(s string, opts ...ParseOptions) (*JsonValue, error) {
+	return a.parse([]byte(s), s, opts)
+}
+
+func (a *Arena) parse(b []byte, text string, opts []ParseOptions) (*JsonValue, error) {
+	opt := getParseOptions(opts)
+	if opt.PreserveFormat {
+		// layouts refer to the input, which the arena does not own
+		o := *opt
+		o.PreserveFormat = false
+		opt = &o
+	}
+	utf, err := toUTF8(b, opt)
+	if err != nil {
+		return nil, err
+	}
+	if len(utf) > 0 && &utf[len(utf)-1] == &b[len(b)-1] {
+		// unconverted, possibly without byte order mark
+		text = text[len(b)-len(utf):]
+	} else {
+		text = string(utf)
+	}
+	p := newParser(utf, opt)
+	p.arena = a
+	p.text = text
+	v, err := p.parseDocument()
+	a.stack = a.stack[:0]
+	a.keyStack = a.keyStack[:0]
+	return v, err
+}
+
+// Release makes the memory of all values parsed so far available to the next documents
+func (a *Arena) Release() {
+	for i := 0; i <= a.node && i < len(a.nodes); i++ {
+		chunk := a.nodes[i]
+		if i == a.node {
+			chunk = chunk[:a.nodeUsed]
+		}
+		for j := range chunk {
+			chunk[j] = JsonValue{}
+		}
+	}
+	for i := 0; i <= a.slab && i < len(a.slabs); i++ {
+		chunk := a.slabs[i]
+		if i == a.slab {
+			chunk = chunk[:a.slabUsed]
+		}
+		for j := range chunk {
+			chunk[j] = nil
+		}
+	}
+	for i := 0; i <= a.keySlab && i < len(a.keySlabs); i++ {
+		chunk := a.keySlabs[i]
+		if i == a.keySlab {
+			chunk = chunk[:a.keySlabUsed]
+		}
+		for j := range chunk {
+			chunk[j] = ""
+		}
+	}
+	for _, m := range a.maps[:a.mapUsed] {
+		for k := range m {
+			delete(m, k)
+		}
+	}
+	a.node, a.nodeUsed = 0, 0
+	a.slab, a.slabUsed = 0, 0
+	a.keySlab, a.keySlabUsed = 0, 0
+	a.mapUsed = 0
+}
+
+// chunkSize returns the size of the n-th chunk
+func chunkSize(n int) int {
+	size := minArenaChunk << uint(n)
+	if size > maxArenaChunk || size <= 0 {
+		return maxArenaChunk
+	}
+	return size
+}
+
+func (a *Arena) newValue() *JsonValue {
+	for {
+		if a.node < len(a.nodes) {
+			chunk := a.nodes[a.node]
+			if a.nodeUsed < len(chunk) {
+				a.nodeUsed++
+				return &chunk[a.nodeUsed-1]
+			}
+			a.node++
+			a.nodeUsed = 0
+			continue
+		}
+		a.nodes = append(a.nodes, make([]JsonValue, chunkSize(len(a.nodes))))
+	}
+}
+
+func (a *Arena) newMap() map[string]*JsonValue {
+	if a.mapUsed < len(a.maps) {
+		a.mapUsed++
+		return a.maps[a.mapUsed-1]
+	}
+	m := make(map[string]*JsonValue)
+	a.maps = append(a.maps, m)
+	a.mapUsed++
+	return m
+}
+
+// children moves the array children stacked from mark on into a slice of the arena
+func (a *Arena) children(mark int) []*JsonValue {
+	list := a.stack[mark:]
+	n := len(list)
+	for {
+		if a.slab < len(a.slabs) {
+			chunk := a.slabs[a.slab]
+			if a.slabUsed+n <= len(chunk) {
+				res := chunk[a.slabUsed : a.slabUsed+n : a.slabUsed+n]
+				copy(res, list)
+				a.slabUsed += n
+				a.stack = a.stack[:mark]
+				return res
+			}
+			a.slab++
+			a.slabUsed = 0
+			continue
+		}
+		size := chunkSize(len(a.slabs))
+		if size < n {
+			size = n
+		}
+		a.slabs = append(a.slabs, make([]*JsonValue, size))
+	}
+}
+
+// keys moves the object keys stacked from mark on into a slice of the arena
+func (a *Arena) keys(mark int) []string {
+	list := a.keyStack[mark:]
+	n := len(list)
+	for {
+		if a.keySlab < len(a.keySlabs) {
+			chunk := a.keySlabs[a.keySlab]
+			if a.keySlabUsed+n <= len(chunk) {
+				res := chunk[a.keySlabUsed : a.keySlabUsed+n : a.keySlabUsed+n]
+				copy(res, list)
+				a.keySlabUsed += n
+				a.keyStack = a.keyStack[:mark]
+				return res
+			}
+			a.keySlab++
+			a.keySlabUsed = 0
+			continue
+		}
+		size := chunkSize(len(a.keySlabs))
+		if size < n {
+			size = n
+		}
+		a.keySlabs = append(a.keySlabs, make([]string, size))
+	}
+}
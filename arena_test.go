@@ -0,0 +1,44 @@
+package jsonconv
+
+import (
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	s := `{"id": 12, "name": "abc", "tags": ["x", "y\n", "z"], "nested": [[], [1, [2, 3]], {"k": null}], "ok": true}`
+	a := NewArena()
+
+	for round := 0; round < 3; round++ {
+		v, err := a.ParseString(s)
+		if err != nil {
+			t.Errorf("ParseString failed: %v", err)
+			return
+		}
+		if res, _ := v.MarshalToString(Option{SortMode: DictAsc, ShowNull: true}); res != `{"id":12,"name":"abc","nested":[[],[1,[2,3]],{"k":null}],"ok":true,"tags":["x","y\n","z"]}` {
+			t.Errorf("unexpected output %s", res)
+		}
+		// appending must not overwrite the elements of other arrays
+		v.AppendString("w", "tags")
+		if n, _ := v.Get("nested", 1, 1, 0); n.Int() != 2 {
+			t.Errorf("array overwritten")
+		}
+		a.Release()
+	}
+
+	if _, err := a.Parse([]byte(`[1, 2`)); err == nil {
+		t.Errorf("error not detected")
+	}
+	a.Release()
+
+	b := []byte(s)
+	heap := testing.AllocsPerRun(100, func() {
+		NewFromBytes(b)
+	})
+	arena := testing.AllocsPerRun(100, func() {
+		a.Parse(b)
+		a.Release()
+	})
+	if arena*3 > heap {
+		t.Errorf("%v allocations with an arena, %v without", arena, heap)
+	}
+}
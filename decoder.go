@@ -0,0 +1,68 @@
+package jsonconv
+
+import (
+	"io"
+)
+
+// Decoder reads consecutive JSON values from a stream, such as concatenated or
+// newline-delimited (NDJSON) documents. Only the document being decoded is kept in memory.
+type Decoder struct {
+	p   *parser
+	err error
+}
+
+func NewDecoder(r io.Reader, opts ...ParseOptions) *Decoder {
+	return &Decoder{
+		p: newStreamParser(r, getParseOptions(opts)),
+	}
+}
+
+// Decode reads the next value from the stream. It returns io.EOF when the stream ends
+// between two values. After any other error the Decoder keeps returning that error.
+func (d *Decoder) Decode() (*JsonValue, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	p := d.p
+	p.compact()
+	p.docStart = p.offset()
+	p.overLimit = false
+	p.skipSpace()
+	if p.eof() {
+		if err := p.readError(); err != nil {
+			d.err = err
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	v, err := p.parseValue()
+	if err == nil {
+		err = p.checkSize()
+	}
+	if err != nil {
+		// a broken stream is reported as such rather than as a truncated document
+		if rd_err := p.readError(); rd_err != nil {
+			err = rd_err
+		}
+		d.err = err
+		return nil, err
+	}
+	return v, nil
+}
+
+// More tells whether there is another value to decode in the stream
+func (d *Decoder) More() bool {
+	if d.err != nil {
+		return false
+	}
+	d.p.skipSpace()
+	return false == d.p.eof()
+}
+
+// InputOffset returns the byte offset of the current decoder position in the stream.
+// Right after Decode it points just past the decoded value.
+func (d *Decoder) InputOffset() int64 {
+	return d.p.offset()
+}
@@ -0,0 +1,81 @@
+package jsonconv
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoder(t *testing.T) {
+	s := "{\"a\": 1}\n[1, 2]\n\"line\\n\"{\"b\":true} 12345 null\n"
+	d := NewDecoder(iotest.OneByteReader(strings.NewReader(s)))
+
+	expected := []string{`{"a":1}`, `[1,2]`, `"line\n"`, `{"b":true}`, `12345`}
+	for i, exp := range expected {
+		if false == d.More() {
+			t.Errorf("missing value %d", i)
+			return
+		}
+		v, err := d.Decode()
+		if err != nil {
+			t.Errorf("Decode failed: %v", err)
+			return
+		}
+		res, _ := v.MarshalToString()
+		if res != exp {
+			t.Errorf("value %d: %s != %s", i, res, exp)
+		}
+	}
+	if d.InputOffset() != 40 {
+		t.Errorf("bad offset %d", d.InputOffset())
+	}
+
+	v, err := d.Decode()
+	if err != nil || false == v.IsNull() {
+		t.Errorf("null expected, got error %v", err)
+	}
+	if d.More() {
+		t.Error("unexpected value")
+	}
+	if _, err = d.Decode(); err != io.EOF {
+		t.Errorf("io.EOF expected, got %v", err)
+	}
+}
+
+func TestDecoderError(t *testing.T) {
+	d := NewDecoder(strings.NewReader("{\"a\": 1}\n{\"b\": }\n"))
+	if _, err := d.Decode(); err != nil {
+		t.Errorf("Decode failed: %v", err)
+	}
+	_, err := d.Decode()
+	var se *SyntaxError
+	if false == errors.As(err, &se) {
+		t.Errorf("SyntaxError expected, got %v", err)
+		return
+	}
+	if se.Line != 2 || se.Column != 7 || se.Offset != 15 {
+		t.Errorf("bad position %d:%d (offset %d)", se.Line, se.Column, se.Offset)
+	}
+
+	// truncated stream
+	d = NewDecoder(strings.NewReader(`[1, 2`))
+	if _, err = d.Decode(); false == errors.Is(err, JsonFormatError) {
+		t.Errorf("JsonFormatError expected, got %v", err)
+	}
+}
+
+func TestDecoderPosition(t *testing.T) {
+	d := NewDecoder(strings.NewReader("{\"a\": 1}\n{\"a\": 2}\n"))
+	d.Decode()
+	v, err := d.Decode()
+	if err != nil {
+		t.Errorf("Decode failed: %v", err)
+		return
+	}
+	a, _ := v.Get("a")
+	if pos := a.Position(); pos.Line != 2 || pos.Column != 7 || pos.Offset != 15 {
+		t.Errorf("bad position %+v", pos)
+	}
+}
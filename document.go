@@ -0,0 +1,280 @@
+package jsonconv
+
+import (
+	"bytes"
+)
+
+// sourceLayout records how a value parsed with ParseOptions.PreserveFormat was written,
+// so that Marshal with Option.PreserveFormat can reproduce it
+type sourceLayout struct {
+	src        []byte
+	start, end int // span of the value in src
+	// text before and after the top-level value, root only
+	lead, trail []byte
+
+	// objects and arrays only
+	fields        map[string]*memberLayout // by key, for objects
+	order         []string                 // keys in source order, then added ones
+	items         []*memberLayout          // parallel to arrChildren, nil for added elements
+	closing       []byte                   // text between the last member and the closing bracket
+	trailingComma bool
+	template      *memberLayout // last member in source, used to format added members
+}
+
+// memberLayout is the text around an object member or array element
+type memberLayout struct {
+	before  []byte // whitespace and comments in front of the member
+	key     []byte // key as written, objects only
+	colon   []byte // text from the end of the key to the value, objects only
+	after   []byte // text between the value and its ','
+	comment []byte // text after the ',' up to the end of the line
+}
+
+// memberPos holds the positions of a member while its container is being parsed
+type memberPos struct {
+	start  int // key or value
+	keyEnd int
+	key    string
+	value  *JsonValue
+	comma  int // -1 if there is none
+}
+
+// ====================
+// parsing
+
+// containerLayout builds the layout of a container spanning src[open:close+1]
+func (p *parser) containerLayout(open, close int, members []memberPos, isObject bool) *sourceLayout {
+	src := p.data
+	lay := &sourceLayout{
+		src:   src,
+		start: open,
+		end:   close + 1,
+	}
+	if isObject {
+		lay.fields = make(map[string]*memberLayout, len(members))
+		lay.order = make([]string, 0, len(members))
+	} else {
+		lay.items = make([]*memberLayout, 0, len(members))
+	}
+	if 0 == len(members) {
+		lay.closing = src[open+1 : close]
+		return lay
+	}
+
+	var prev *memberLayout
+	sep_end := open + 1
+	for _, m := range members {
+		ml := &memberLayout{}
+		if nil == prev {
+			ml.before = src[sep_end:m.start]
+		} else {
+			prev.comment, ml.before = splitLine(src[sep_end:m.start])
+		}
+		if isObject {
+			ml.key = src[m.start:m.keyEnd]
+			ml.colon = src[m.keyEnd:m.value.layout.start]
+		}
+		if m.comma >= 0 {
+			ml.after = src[m.value.layout.end:m.comma]
+			sep_end = m.comma + 1
+		} else {
+			sep_end = m.value.layout.end
+		}
+
+		if isObject {
+			if _, exist := lay.fields[m.key]; false == exist {
+				lay.order = append(lay.order, m.key)
+			}
+			lay.fields[m.key] = ml
+		} else {
+			lay.items = append(lay.items, ml)
+		}
+		prev = ml
+	}
+
+	lay.trailingComma = members[len(members)-1].comma >= 0
+	prev.comment, lay.closing = splitLine(src[sep_end:close])
+	lay.template = prev
+	return lay
+}
+
+// splitLine splits b after the text on its first line
+func splitLine(b []byte) (line []byte, rest []byte) {
+	idx := bytes.IndexByte(b, '\n')
+	if idx < 0 {
+		return nil, b
+	}
+	if idx > 0 && b[idx-1] == '\r' {
+		idx--
+	}
+	return b[:idx], b[idx:]
+}
+
+// indentOf returns the line break and indentation of before, without any comments
+func indentOf(before []byte) []byte {
+	idx := bytes.LastIndexByte(before, '\n')
+	if idx < 0 {
+		if 0 == len(bytes.TrimSpace(before)) {
+			return before
+		}
+		return []byte{' '}
+	}
+	from := idx
+	if idx > 0 && before[idx-1] == '\r' {
+		from--
+	}
+	to := idx + 1
+	for to < len(before) && (before[to] == ' ' || before[to] == '\t') {
+		to++
+	}
+	return before[from:to]
+}
+
+// ====================
+// marshal
+
+func (obj *JsonValue) marshalLayout(buff marshalWriter, opt *Option) error {
+	lay := obj.layout
+	buff.Write(lay.lead)
+	defer buff.Write(lay.trail)
+	if opt.indented() {
+		// the source layout is kept, and added values are written compactly
+		o := *opt
+		o.Indent, o.Prefix = "", ""
+		opt = &o
+	}
+
+	if obj.valueType != Object && obj.valueType != Array {
+		buff.Write(lay.src[lay.start:lay.end])
+		return nil
+	}
+
+	type member struct {
+		key   string
+		value *JsonValue
+		lay   *memberLayout
+	}
+	members := make([]member, 0, obj.Length())
+	if obj.valueType == Object {
+		for _, key := range obj.layoutKeys() {
+			members = append(members, member{key, obj.objChildren[key], lay.fields[key]})
+		}
+	} else {
+		for i, child := range obj.arrChildren {
+			m := member{value: child}
+			if i < len(lay.items) {
+				m.lay = lay.items[i]
+			}
+			members = append(members, m)
+		}
+	}
+
+	buff.WriteByte(lay.src[lay.start])
+	for i, m := range members {
+		ml := m.lay
+		if nil == ml {
+			ml = &memberLayout{}
+			if lay.template != nil {
+				ml.before = indentOf(lay.template.before)
+				ml.colon = lay.template.colon
+			}
+		}
+
+		buff.Write(ml.before)
+		if obj.valueType == Object {
+			if ml.key != nil {
+				buff.Write(ml.key)
+			} else {
+				buff.WriteString(`"` + escapeJsonString(m.key, opt) + `"`)
+			}
+			if ml.colon != nil {
+				buff.Write(ml.colon)
+			} else {
+				buff.WriteByte(':')
+			}
+		}
+		if err := m.value.marshalToBuffer(buff, *opt); err != nil {
+			return err
+		}
+		buff.Write(ml.after)
+		if i < len(members)-1 || lay.trailingComma {
+			buff.WriteByte(',')
+		}
+		buff.Write(ml.comment)
+	}
+	buff.Write(lay.closing)
+	buff.WriteByte(lay.src[lay.end-1])
+	return nil
+}
+
+// layoutKeys returns the keys of an object in source order, followed by the added ones
+// in the order they were added
+func (obj *JsonValue) layoutKeys() []string {
+	lay := obj.layout
+	keys := make([]string, 0, len(obj.objChildren))
+	listed := make(map[string]bool, len(lay.order))
+	for _, key := range lay.order {
+		listed[key] = true
+		if _, exist := obj.objChildren[key]; exist {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range obj.objKeys {
+		if false == listed[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// ====================
+// modification hooks
+
+func (obj *JsonValue) layoutAddKey(key string) {
+	lay := obj.layout
+	if nil == lay || nil == lay.fields {
+		return
+	}
+	for _, k := range lay.order {
+		if k == key {
+			return
+		}
+	}
+	lay.order = append(lay.order, key)
+}
+
+func (obj *JsonValue) layoutInsert(index int) {
+	lay := obj.layout
+	if nil == lay || index > len(lay.items) {
+		return
+	}
+	lay.items = append(lay.items[:index], append([]*memberLayout{nil}, lay.items[index:]...)...)
+}
+
+func (obj *JsonValue) layoutDelete(index int) {
+	lay := obj.layout
+	if nil == lay || index >= len(lay.items) {
+		return
+	}
+	if 0 == index && len(lay.items) > 1 && lay.items[0] != nil {
+		// the new first item takes the text after the opening bracket
+		next := &memberLayout{}
+		if lay.items[1] != nil {
+			*next = *lay.items[1]
+		}
+		next.before = lay.items[0].before
+		lay.items[1] = next
+	}
+	lay.items = append(lay.items[:index], lay.items[index+1:]...)
+}
+
+func (obj *JsonValue) layoutSwap(i, j int) {
+	lay := obj.layout
+	if nil == lay {
+		return
+	}
+	for len(lay.items) <= i || len(lay.items) <= j {
+		lay.items = append(lay.items, nil)
+	}
+	lay.items[i], lay.items[j] = lay.items[j], lay.items[i]
+}
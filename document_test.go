@@ -0,0 +1,78 @@
+package jsonconv
+
+import (
+	"testing"
+)
+
+func TestPreserveFormat(t *testing.T) {
+	src := "// service config\n{\n" +
+		"    \"name\":  \"demo\",   // display name\n" +
+		"    \"port\": 8080,\n" +
+		"    /* upstream servers */\n" +
+		"    \"hosts\": [ \"a\", \"b\" ],\n" +
+		"    \"ratio\": 1.50,\n" +
+		"    \"debug\": null // last\n" +
+		"}\n"
+	opt := Option{PreserveFormat: true}
+
+	v, err := NewFromString(src, ParseOptions{PreserveFormat: true})
+	if err != nil {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+	if s, _ := v.MarshalToString(opt); s != src {
+		t.Errorf("unmodified document changed:\n%s", s)
+	}
+
+	v.SetString("prod", "name")
+	v.Delete("port")
+	v.AppendString("c", "hosts")
+	v.SetBool(true, "verbose")
+	exp := "// service config\n{\n" +
+		"    \"name\":  \"prod\",   // display name\n" +
+		"    /* upstream servers */\n" +
+		"    \"hosts\": [ \"a\", \"b\", \"c\" ],\n" +
+		"    \"ratio\": 1.50,\n" +
+		"    \"debug\": null, // last\n" +
+		"    \"verbose\": true\n" +
+		"}\n"
+	if s, _ := v.MarshalToString(opt); s != exp {
+		t.Errorf("unexpected output:\n%s", s)
+	}
+
+	// without the option the value is written as usual
+	if s, _ := v.MarshalToString(Option{SortMode: DictAsc}); s != `{"hosts":["a","b","c"],"name":"prod","ratio":1.50,"verbose":true}` {
+		t.Errorf("unexpected output %s", s)
+	}
+}
+
+func TestPreserveFormatArray(t *testing.T) {
+	src := "[\n  1, // one\n  2,\n  3\n]"
+	v, err := NewFromString(src, ParseOptions{PreserveFormat: true})
+	if err != nil {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+	v.Delete(1)
+	v.Insert(NewInt(0), 0)
+	v.Swap(1, 2)
+	exp := "[\n  0,\n  3,\n  1 // one\n]"
+	if s, _ := v.MarshalToString(Option{PreserveFormat: true}); s != exp {
+		t.Errorf("unexpected output:\n%s", s)
+	}
+}
+
+func TestPreserveFormatDeleteFirst(t *testing.T) {
+	src := "{\"list\": [1, 2, 3], \"rows\": [\n    1,\n    2\n  ]}"
+	v, err := NewFromString(src, ParseOptions{PreserveFormat: true})
+	if err != nil {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+	v.Delete("list", 0)
+	v.Delete("rows", 0)
+	exp := "{\"list\": [2, 3], \"rows\": [\n    2\n  ]}"
+	if s, _ := v.MarshalToString(Option{PreserveFormat: true}); s != exp {
+		t.Errorf("unexpected output:\n%s", s)
+	}
+}
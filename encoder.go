@@ -0,0 +1,73 @@
+package jsonconv
+
+import (
+	"bufio"
+	"io"
+)
+
+// size of the chunks an Encoder writes
+const encoderBufferSize = 32 * 1024
+
+// marshalWriter is what values are marshaled to: a bytes.Buffer, or a bufio.Writer which
+// passes the output on in chunks
+type marshalWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+	WriteRune(r rune) (int, error)
+}
+
+// writeError returns the error of a writer which has failed. A bufio.Writer keeps
+// returning the error of its underlying writer, even for an empty Write.
+func writeError(w marshalWriter) error {
+	_, err := w.Write(nil)
+	return err
+}
+
+// Encoder writes JSON values to a stream. The output goes through a buffer of bounded
+// size instead of being built in memory first.
+type Encoder struct {
+	w   *bufio.Writer
+	opt Option
+	err error
+}
+
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	e := &Encoder{
+		w:   bufio.NewWriterSize(w, encoderBufferSize),
+		opt: dftOption,
+	}
+	if len(opts) > 0 {
+		e.opt = opts[0]
+	}
+	return e
+}
+
+// Encode writes v followed by a newline. After an error the Encoder keeps returning that
+// error.
+func (e *Encoder) Encode(v *JsonValue) error {
+	if e.err != nil {
+		return e.err
+	}
+	if nil == v {
+		return ParaError
+	}
+	err := v.marshalToBuffer(e.w, e.opt)
+	if err == nil {
+		e.w.WriteByte('\n')
+		err = e.w.Flush()
+	}
+	if err != nil {
+		e.err = err
+	}
+	return err
+}
+
+// MarshalTo writes the marshaled value to w, in chunks of bounded size
+func (obj *JsonValue) MarshalTo(w io.Writer, opts ...Option) error {
+	bw := bufio.NewWriterSize(w, encoderBufferSize)
+	if err := obj.marshalToBuffer(bw, opts...); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
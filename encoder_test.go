@@ -0,0 +1,85 @@
+package jsonconv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// limitedWriter fails once more than n bytes have been written
+type limitedWriter struct {
+	n       int
+	written int
+	largest int
+}
+
+var errWriterFull = errors.New("writer full")
+
+func (w *limitedWriter) Write(b []byte) (int, error) {
+	if len(b) > w.largest {
+		w.largest = len(b)
+	}
+	if w.written+len(b) > w.n {
+		return 0, errWriterFull
+	}
+	w.written += len(b)
+	return len(b), nil
+}
+
+func TestEncoder(t *testing.T) {
+	buff := bytes.Buffer{}
+	e := NewEncoder(&buff, Option{SortMode: DictAsc})
+	for _, s := range []string{`{"b":[1,2],"a":"x"}`, `null`, `["line\n"]`} {
+		v, _ := NewFromString(s)
+		if err := e.Encode(v); err != nil {
+			t.Errorf("Encode failed: %v", err)
+			return
+		}
+	}
+	if buff.String() != "{\"a\":\"x\",\"b\":[1,2]}\nnull\n[\"line\\n\"]\n" {
+		t.Errorf("unexpected output %q", buff.String())
+	}
+}
+
+func TestMarshalTo(t *testing.T) {
+	arr := NewArray()
+	for i := 0; i < 20000; i++ {
+		arr.Append(NewString("some text to fill the output"))
+	}
+	expected, _ := arr.Marshal()
+
+	w := &limitedWriter{n: len(expected)}
+	if err := arr.MarshalTo(w); err != nil {
+		t.Errorf("MarshalTo failed: %v", err)
+	}
+	if w.written != len(expected) || w.largest > encoderBufferSize {
+		t.Errorf("wrote %d bytes in chunks of up to %d", w.written, w.largest)
+	}
+
+	w = &limitedWriter{n: len(expected) / 2}
+	if err := arr.MarshalTo(w); false == errors.Is(err, errWriterFull) {
+		t.Errorf("unexpected error %v", err)
+	}
+	// marshaling stops at the first write error, before reaching the broken last element
+	b := bytes.Buffer{}
+	b.WriteString(`[`)
+	for i := 0; i < 20000; i++ {
+		b.WriteString(`"some text to fill the output",`)
+	}
+	b.WriteString(`{"k": 1, "k": 2}]`)
+	lazy, _ := NewLazyFromBytes(b.Bytes(), ParseOptions{DuplicateKeys: DuplicateError})
+	if err := lazy.MarshalTo(&limitedWriter{n: 100}); false == errors.Is(err, errWriterFull) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := lazy.MarshalTo(&limitedWriter{n: b.Len()}); false == errors.Is(err, DuplicateKeyError) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	e := NewEncoder(&limitedWriter{})
+	if err := e.Encode(arr); false == errors.Is(err, errWriterFull) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := e.Encode(NewNull()); false == errors.Is(err, errWriterFull) {
+		t.Errorf("error not kept: %v", err)
+	}
+}
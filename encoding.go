@@ -0,0 +1,159 @@
+package jsonconv
+
+import (
+	"bytes"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of a JSON text
+type Encoding int
+
+const (
+	EncodingAuto    Encoding = iota // detect from the byte order mark or the RFC 4627 null-byte pattern
+	EncodingUTF8                    // UTF-8, with or without byte order mark
+	EncodingUTF16LE                 // UTF-16 little endian
+	EncodingUTF16BE                 // UTF-16 big endian
+	EncodingUTF32LE                 // UTF-32 little endian
+	EncodingUTF32BE                 // UTF-32 big endian
+)
+
+var byteOrderMarks = []struct {
+	enc Encoding
+	bom []byte
+}{
+	// UTF-32LE before UTF-16LE, whose mark is a prefix of it
+	{EncodingUTF8, []byte{0xEF, 0xBB, 0xBF}},
+	{EncodingUTF32LE, []byte{0xFF, 0xFE, 0x00, 0x00}},
+	{EncodingUTF32BE, []byte{0x00, 0x00, 0xFE, 0xFF}},
+	{EncodingUTF16LE, []byte{0xFF, 0xFE}},
+	{EncodingUTF16BE, []byte{0xFE, 0xFF}},
+}
+
+// detectEncoding returns the encoding of b and the length of its byte order mark
+func detectEncoding(b []byte) (Encoding, int) {
+	for _, m := range byteOrderMarks {
+		if bytes.HasPrefix(b, m.bom) {
+			return m.enc, len(m.bom)
+		}
+	}
+
+	// a JSON text starts with an ASCII character, so the position of the null bytes
+	// around it tells the encoding
+	switch {
+	case len(b) >= 4 && b[0] == 0 && b[1] == 0 && b[2] == 0 && b[3] != 0:
+		return EncodingUTF32BE, 0
+	case len(b) >= 4 && b[0] != 0 && b[1] == 0 && b[2] == 0 && b[3] == 0:
+		return EncodingUTF32LE, 0
+	case len(b) >= 2 && b[0] == 0 && b[1] != 0:
+		return EncodingUTF16BE, 0
+	case len(b) >= 2 && b[0] != 0 && b[1] == 0:
+		return EncodingUTF16LE, 0
+	}
+	return EncodingUTF8, 0
+}
+
+// toUTF8 converts b from the configured encoding to UTF-8, without byte order mark.
+// UTF-8 input is returned as it is.
+func toUTF8(b []byte, opt *ParseOptions) ([]byte, error) {
+	enc, bom := opt.Encoding, 0
+	if enc == EncodingAuto {
+		enc, bom = detectEncoding(b)
+	} else {
+		// only a mark of the forced encoding is removed
+		for _, m := range byteOrderMarks {
+			if m.enc == enc && bytes.HasPrefix(b, m.bom) {
+				bom = len(m.bom)
+			}
+		}
+	}
+	b = b[bom:]
+
+	switch enc {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		return utf16ToUTF8(b, bom, enc == EncodingUTF16BE, opt)
+	case EncodingUTF32LE, EncodingUTF32BE:
+		return utf32ToUTF8(b, bom, enc == EncodingUTF32BE, opt)
+	default:
+		return b, nil
+	}
+}
+
+func utf16ToUTF8(b []byte, offset int, bigEndian bool, opt *ParseOptions) ([]byte, error) {
+	if len(b)%2 != 0 {
+		res, _ := utf16ToUTF8(b[:len(b)-1], offset, bigEndian, opt)
+		return nil, encodingError(res, offset+len(b)-1, "truncated UTF-16 input")
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		} else {
+			units[i] = uint16(b[2*i+1])<<8 | uint16(b[2*i])
+		}
+	}
+
+	res := make([]byte, 0, len(units))
+	for i := 0; i < len(units); i++ {
+		r := rune(units[i])
+		if utf16.IsSurrogate(r) {
+			if i+1 < len(units) {
+				r = utf16.DecodeRune(r, rune(units[i+1]))
+			} else {
+				r = utf8.RuneError
+			}
+			if r == utf8.RuneError {
+				if opt.InvalidUTF8 == InvalidUTF8Reject {
+					return nil, encodingError(res, offset+2*i, "invalid UTF-16 surrogate")
+				}
+			} else {
+				i++
+			}
+		}
+		res = appendRune(res, r)
+	}
+	return res, nil
+}
+
+func utf32ToUTF8(b []byte, offset int, bigEndian bool, opt *ParseOptions) ([]byte, error) {
+	if len(b)%4 != 0 {
+		end := len(b) - len(b)%4
+		res, _ := utf32ToUTF8(b[:end], offset, bigEndian, opt)
+		return nil, encodingError(res, offset+end, "truncated UTF-32 input")
+	}
+	res := make([]byte, 0, len(b)/4)
+	for i := 0; i < len(b); i += 4 {
+		var r rune
+		if bigEndian {
+			r = rune(b[i])<<24 | rune(b[i+1])<<16 | rune(b[i+2])<<8 | rune(b[i+3])
+		} else {
+			r = rune(b[i+3])<<24 | rune(b[i+2])<<16 | rune(b[i+1])<<8 | rune(b[i])
+		}
+		if false == utf8.ValidRune(r) {
+			if opt.InvalidUTF8 == InvalidUTF8Reject {
+				return nil, encodingError(res, offset+i, "invalid UTF-32 character")
+			}
+			r = utf8.RuneError
+		}
+		res = appendRune(res, r)
+	}
+	return res, nil
+}
+
+func appendRune(b []byte, r rune) []byte {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return append(b, buf[:n]...)
+}
+
+// encodingError reports an error at byte offset pos of the original input. done is the
+// text converted so far, which gives the line and column.
+func encodingError(done []byte, pos int, msg string) *SyntaxError {
+	line_start := bytes.LastIndexByte(done, '\n') + 1
+	return &SyntaxError{
+		Msg:    msg,
+		Offset: int64(pos),
+		Line:   bytes.Count(done, []byte{'\n'}) + 1,
+		Column: len(done) - line_start + 1,
+	}
+}
@@ -0,0 +1,147 @@
+package jsonconv
+
+import (
+	"io"
+)
+
+// ExtractFunc receives a value found at a registered path. keys holds the concrete
+// object keys (string) and array indexes (int) leading to it, in the same form as the
+// arguments of JsonValue.Get.
+type ExtractFunc func(keys []interface{}, v *JsonValue) error
+
+type extractHandler struct {
+	path []pathElem
+	fn   ExtractFunc
+}
+
+// Extractor reads a JSON stream and passes every value found at one of the registered
+// paths to its handler as soon as it has been parsed. Everything else is skipped without
+// being built.
+type Extractor struct {
+	tk       *Tokenizer
+	handlers []extractHandler
+}
+
+func NewExtractor(r io.Reader, opts ...ParseOptions) *Extractor {
+	return &Extractor{
+		tk: NewTokenizer(r, opts...),
+	}
+}
+
+// Handle registers fn for every value at path. Paths are written like `items[*].id`,
+// `meta.cursor`, `*.name` or `["dotted.key"][0]`, where `*` matches any object key and
+// `[*]` any array index. An empty path matches every top-level value.
+func (e *Extractor) Handle(path string, fn ExtractFunc) error {
+	if nil == fn {
+		return ParaError
+	}
+	elems, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	e.handlers = append(e.handlers, extractHandler{path: elems, fn: fn})
+	return nil
+}
+
+// Run reads the whole stream, which may contain several top-level values. It stops at
+// the first syntax error or the first error returned by a handler.
+func (e *Extractor) Run() error {
+	keys := make([]interface{}, 0, 16)
+	for {
+		if _, err := e.tk.prepare(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := e.walk(keys); err != nil {
+			return err
+		}
+	}
+}
+
+// walk handles the value at the current tokenizer position
+func (e *Extractor) walk(keys []interface{}) error {
+	matched, descend := e.match(keys)
+	if matched {
+		v, err := e.tk.ReadValue()
+		if err != nil {
+			return err
+		}
+		return e.dispatch(keys, v)
+	}
+	if false == descend {
+		return e.tk.Skip()
+	}
+
+	tok, err := e.tk.Next()
+	if err != nil {
+		return err
+	}
+	switch tok.Kind {
+	case TokenBeginObject:
+		for e.tk.More() {
+			key, err := e.tk.Next()
+			if err != nil {
+				return err
+			}
+			if err = e.walk(append(keys, key.Value)); err != nil {
+				return err
+			}
+		}
+	case TokenBeginArray:
+		for i := 0; e.tk.More(); i++ {
+			if err = e.walk(append(keys, i)); err != nil {
+				return err
+			}
+		}
+	default:
+		// a scalar where a container was expected, nothing to extract
+		return nil
+	}
+
+	// closing '}' or ']', or the error which stopped More
+	_, err = e.tk.Next()
+	return err
+}
+
+// match tells whether any handler addresses the value at keys or one of its descendants
+func (e *Extractor) match(keys []interface{}) (matched bool, descend bool) {
+	for _, h := range e.handlers {
+		exact, prefix := matchPath(h.path, keys)
+		matched = matched || exact
+		descend = descend || prefix
+	}
+	return
+}
+
+// dispatch calls the handlers of v and of all its descendants which are addressed by
+// other handlers
+func (e *Extractor) dispatch(keys []interface{}, v *JsonValue) error {
+	descend := false
+	for _, h := range e.handlers {
+		exact, prefix := matchPath(h.path, keys)
+		if exact {
+			if err := h.fn(append([]interface{}{}, keys...), v); err != nil {
+				return err
+			}
+		}
+		descend = descend || prefix
+	}
+	if false == descend {
+		return nil
+	}
+
+	switch v.valueType {
+	case Object:
+		return v.ObjectForeach(func(key string, child *JsonValue) error {
+			return e.dispatch(append(keys, key), child)
+		})
+	case Array:
+		return v.ArrayForeach(func(i int, child *JsonValue) error {
+			return e.dispatch(append(keys, i), child)
+		})
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,72 @@
+package jsonconv
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExtractor(t *testing.T) {
+	s := `{
+		"items": [{"id": 1, "tags": ["a"]}, {"id": 2}, {"name": "x"}],
+		"meta": {"cursor": "abc", "total": 3},
+		"skipped": {"items": [{"id": 0}]}
+	}`
+	ex := NewExtractor(strings.NewReader(s))
+
+	res := []string{}
+	collect := func(keys []interface{}, v *JsonValue) error {
+		str, _ := v.MarshalToString(Option{SortMode: DictAsc})
+		res = append(res, fmt.Sprintf("%v=%s", keys, str))
+		return nil
+	}
+	for _, path := range []string{"items[*].id", "meta.cursor", "items[0]", "items[0].tags[0]"} {
+		if err := ex.Handle(path, collect); err != nil {
+			t.Errorf("Handle(%s) failed: %v", path, err)
+		}
+	}
+	if err := ex.Handle("items[x]", collect); err != PathFormatError {
+		t.Errorf("PathFormatError expected, got %v", err)
+	}
+
+	if err := ex.Run(); err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+	// children of a value which was built in full are visited in map order
+	sort.Strings(res)
+	expected := []string{
+		`[items 0 id]=1`,
+		`[items 0 tags 0]="a"`,
+		`[items 0]={"id":1,"tags":["a"]}`,
+		`[items 1 id]=2`,
+		`[meta cursor]="abc"`,
+	}
+	if fmt.Sprint(res) != fmt.Sprint(expected) {
+		t.Errorf("unexpected result %v", res)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	path, err := parsePath(`a.*[2]["b.c]"][*]`)
+	if err != nil {
+		t.Errorf("parsePath failed: %v", err)
+		return
+	}
+	expected := []pathElem{
+		{kind: elemKey, key: "a"},
+		{kind: elemAnyKey},
+		{kind: elemIndex, index: 2},
+		{kind: elemKey, key: "b.c]"},
+		{kind: elemAnyIndex},
+	}
+	if fmt.Sprint(path) != fmt.Sprint(expected) {
+		t.Errorf("unexpected path %v", path)
+	}
+
+	for _, s := range []string{".a", "a.", "a..b", "a[", "a[-1]", "a[0]b", `a["b]`} {
+		if _, err := parsePath(s); err == nil {
+			t.Errorf("error not detected for %s", s)
+		}
+	}
+}
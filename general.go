@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 var (
@@ -21,7 +23,22 @@ var (
 	NotANumberError  = errors.New("target is not a number")
 	NotABoolError    = errors.New("target is not a bool")
 
+	NotAnIntegerError = errors.New("number is not an integer")
+	NumberRangeError  = errors.New("number exponent out of range")
+
 	ObjectNotFoundError = errors.New("object not found")
+
+	NotAValueError  = errors.New("no value at current position")
+	PathFormatError = errors.New("path format error")
+
+	DuplicateKeyError = errors.New("duplicate object key")
+
+	DepthLimitError        = errors.New("nesting depth limit exceeded")
+	SizeLimitError         = errors.New("document size limit exceeded")
+	StringLengthLimitError = errors.New("string length limit exceeded")
+	NumberLengthLimitError = errors.New("number length limit exceeded")
+	ObjectKeysLimitError   = errors.New("object key count limit exceeded")
+	ArrayLengthLimitError  = errors.New("array length limit exceeded")
 )
 
 type Filter int
@@ -35,7 +52,7 @@ const (
 type Sort int
 
 const (
-	Random Sort = iota
+	Random Sort = iota // object keys in the order they were parsed or added
 	DictAsc
 	DictDesc
 )
@@ -45,6 +62,12 @@ type Option struct {
 	ShowNull    bool
 	FloatDigits uint8
 	SortMode    Sort
+	// KeyOrder sorts the keys of objects, instead of SortMode. PathKeyOrder sets it for
+	// the objects at given paths, in the syntax of Extractor.Handle. When several paths
+	// match an object, the one with the fewest wildcards wins, and among those the
+	// smallest path string, e.g. `*.b` before `a.*`.
+	KeyOrder     KeyOrder
+	PathKeyOrder map[string]KeyOrder
 	// for sql2json
 	TimeDigits uint8
 	FilterMode Filter
@@ -52,6 +75,47 @@ type Option struct {
 	// for JsonValue.MergeFrom()
 	OverrideArray  bool
 	OverrideObject bool
+	// PreserveFormat writes values parsed with ParseOptions.PreserveFormat back the
+	// way they were read, re-formatting only what has been modified since
+	PreserveFormat bool
+	// pretty printing like json.MarshalIndent: when Indent or Prefix is set, every member
+	// and element starts on a new line beginning with Prefix and one Indent per level.
+	// Newline is the line break to use, "\n" if empty. Empty objects and arrays stay on
+	// one line. Documents written with PreserveFormat keep their source layout instead.
+	Indent  string
+	Prefix  string
+	Newline string
+	// string escaping, for strings and keys alike. By default only what RFC 8259
+	// requires is escaped: quotes, backslashes and control characters.
+	EnsureASCII    bool // write every non-ASCII character as \uXXXX
+	EscapeHTML     bool // write <, > and & as \u003c, \u003e and \u0026
+	EscapeSlash    bool // write / as \/
+	JavaScriptSafe bool // write U+2028 and U+2029, which end lines in JavaScript, as \uXXXX
+}
+
+func (opt *Option) indented() bool {
+	return opt.Indent != "" || opt.Prefix != ""
+}
+
+// escapesMore tells whether strings are escaped beyond what RFC 8259 requires
+func (opt *Option) escapesMore() bool {
+	return opt.EnsureASCII || opt.EscapeHTML || opt.EscapeSlash || opt.JavaScriptSafe
+}
+
+// newline starts the line of a member or closing bracket depth levels deep
+func (opt *Option) newline(buff marshalWriter, depth int) {
+	if false == opt.indented() {
+		return
+	}
+	if opt.Newline != "" {
+		buff.WriteString(opt.Newline)
+	} else {
+		buff.WriteByte('\n')
+	}
+	buff.WriteString(opt.Prefix)
+	for i := 0; i < depth; i++ {
+		buff.WriteString(opt.Indent)
+	}
 }
 
 var dftOption = Option{
@@ -64,14 +128,90 @@ var dftOption = Option{
 	OverrideObject: false,
 }
 
-func escapeJsonString(s string, ensureAscii bool) string {
+// ParseOptions control how JSON texts are parsed
+type ParseOptions struct {
+	// Strict rejects everything RFC 8259 does not allow: trailing data after the top-level
+	// value, leading zeros, raw control characters in strings, unknown escapes, lone
+	// surrogates and literals which are not followed by a delimiter.
+	Strict bool
+	// Relaxed accepts the JSON5 extensions commonly found in hand-written files: // and
+	// /* */ comments, trailing commas, single-quoted strings, unquoted keys, hexadecimal
+	// numbers, leading '+' or '.', Infinity and NaN.
+	Relaxed bool
+	// InvalidUTF8 tells what to do with malformed UTF-8 in strings and keys
+	InvalidUTF8 UTF8Policy
+	// DuplicateKeys tells which value to keep when a key appears twice in an object
+	DuplicateKeys DuplicatePolicy
+	// Encoding of the input, detected from its byte order mark or its null bytes by
+	// default. NewFromBytes, NewFromString, NewLazyFromBytes and Arena convert other
+	// encodings to UTF-8 before parsing, positions in errors and values then refer to
+	// the converted text.
+	Encoding Encoding
+	// OnKey is called with every object key before the member is parsed. It returns the
+	// key to use instead, or keep=false to leave the member out without parsing its value.
+	// An error stops parsing and is available through the SyntaxError's Err.
+	OnKey func(key string) (newKey string, keep bool, err error)
+	// OnValue is called with every string, number, bool and null in an object or array
+	// before it is inserted. It returns the value to insert instead, or nil to leave it
+	// out. An error stops parsing like for OnKey.
+	OnValue func(v *JsonValue) (*JsonValue, error)
+
+	// PreserveFormat keeps comments, whitespace, key order and the original literals of
+	// a document, see Option.PreserveFormat. It implies accepting // and /* */ comments.
+	// Only NewFromBytes and NewFromString support it, and OnKey and OnValue are not
+	// called with it.
+	PreserveFormat bool
+
+	// limits for untrusted input, zero means unlimited
+	MaxDepth        int // nesting of objects and arrays
+	MaxBytes        int // size of a document, or of the whole input for Tokenizer
+	MaxStringLength int // decoded bytes of a string or key
+	MaxNumberLength int // characters of a number literal
+	MaxObjectKeys   int // members of a single object
+	MaxArrayLength  int // elements of a single array
+}
+
+type DuplicatePolicy int
+
+const (
+	DuplicateLastWins  DuplicatePolicy = iota // later values override earlier ones
+	DuplicateFirstWins                        // later values are ignored
+	DuplicateError                            // fail with DuplicateKeyError
+	DuplicateCollect                          // gather all values of the key into an array
+)
+
+type UTF8Policy int
+
+const (
+	InvalidUTF8PassThrough UTF8Policy = iota // keep the bytes as they are
+	InvalidUTF8Replace                       // replace every invalid byte with U+FFFD
+	InvalidUTF8Reject                        // fail with a syntax error
+)
+
+var dftParseOptions = ParseOptions{
+	Strict:        false,
+	Relaxed:       false,
+	InvalidUTF8:   InvalidUTF8PassThrough,
+	DuplicateKeys: DuplicateLastWins,
+}
+
+func getParseOptions(opts []ParseOptions) *ParseOptions {
+	if len(opts) > 0 {
+		return &(opts[0])
+	}
+	return &dftParseOptions
+}
+
+// escapeJsonString escapes s for a JSON string literal. Only quotes, backslashes and
+// control characters are escaped unless opt asks for more.
+func escapeJsonString(s string, opt *Option) string {
 	b := bytes.Buffer{}
 	for _, chr := range s {
 		switch chr {
 		case '"':
 			b.WriteString("\\\"")
-		case '/':
-			b.WriteString("\\/")
+		case '\\':
+			b.WriteString("\\\\")
 		case '\b':
 			b.WriteString("\\b")
 		case '\f':
@@ -82,29 +222,34 @@ func escapeJsonString(s string, ensureAscii bool) string {
 			b.WriteString("\\n")
 		case '\r':
 			b.WriteString("\\r")
-		case '<':
-			if ensureAscii {
-				b.WriteString("\\u003c")
+		case '/':
+			if opt.EscapeSlash {
+				b.WriteString("\\/")
 			} else {
-				b.WriteByte('<')
+				b.WriteByte('/')
 			}
-		case '>':
-			if ensureAscii {
-				b.WriteString("\\u003e")
+		case '<', '>', '&':
+			if opt.EscapeHTML {
+				b.WriteString(fmt.Sprintf("\\u%04x", chr))
 			} else {
-				b.WriteByte('>')
+				b.WriteRune(chr)
 			}
-		case '&':
-			if ensureAscii {
-				b.WriteString("\\u0026")
+		case '\u2028', '\u2029':
+			if opt.JavaScriptSafe || opt.EnsureASCII {
+				b.WriteString(fmt.Sprintf("\\u%04x", chr))
 			} else {
-				b.WriteByte('&')
+				b.WriteRune(chr)
 			}
-		case '%':
-			b.WriteString("\\u0025")
 		default:
-			if ensureAscii && chr > '\u0127' {
+			if chr < 0x20 {
 				b.WriteString(fmt.Sprintf("\\u%04x", chr))
+			} else if opt.EnsureASCII && chr >= utf8.RuneSelf {
+				// characters out of the BMP are written as UTF-16 surrogate pairs
+				if r1, r2 := utf16.EncodeRune(chr); r1 != utf8.RuneError {
+					b.WriteString(fmt.Sprintf("\\u%04x\\u%04x", r1, r2))
+				} else {
+					b.WriteString(fmt.Sprintf("\\u%04x", chr))
+				}
 			} else {
 				b.WriteRune(chr)
 			}
@@ -2,11 +2,13 @@ package jsonconv
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/buger/jsonparser"
 )
@@ -34,384 +36,300 @@ type JsonValue struct {
 	floatValue  float64
 	boolValue   bool
 	uintValue   uint64
-	// object children
+	// object children, and their keys in insertion order
 	objChildren map[string]*JsonValue
+	objKeys     []string
+	foreach     int // running ObjectForeach calls, which range over objKeys
 	// array children
 	arrChildren []*JsonValue
 	// number type judgement
 	mustSigned   bool
 	mustUnsigned bool
 	mustFloat    bool
+	// original literal of parsed numbers
+	literal string
+	// where the value was parsed from
+	pos Position
+	// source text, for values parsed with ParseOptions.PreserveFormat
+	layout *sourceLayout
+	// unparsed members, for containers parsed with NewLazyFromBytes
+	lazy *lazySource
 }
 
 // ====================
 // internal functions
 
-var escapeMap = map[rune]rune{
-	'"': '"',
-	'/': '/',
-	'b': '\b',
-	'f': '\f',
-	't': '\t',
-	'n': '\n',
+var escapeMap = map[byte]byte{
+	'"':  '"',
+	'\\': '\\',
+	'/':  '/',
+	'b':  '\b',
+	'f':  '\f',
+	't':  '\t',
+	'n':  '\n',
+	'r':  '\r',
 }
 
-func stringFromEscapedBytes(input []byte) (string, error) {
+// escapeError reports an invalid escape sequence and its byte offset in the escaped input
+type escapeError struct {
+	offset int
+	msg    string
+}
+
+func (e *escapeError) Error() string {
+	return e.msg
+}
+
+func stringFromEscapedBytes(input []byte, opt *ParseOptions) (string, error) {
 	b := bytes.Buffer{}
-	s := string(input)
-	escaping := false
-	skip := 0
-
-	for i, chr := range s {
-		if skip > 0 {
-			skip--
-		} else if escaping {
-			escaping = false
-			switch chr {
-			case '"', '/', 'b', 'f', 't', 'n', 'r':
-				write_chr, exist := escapeMap[chr]
-				if exist {
-					b.WriteRune(write_chr)
-				}
-			case 'u':
-				// parse unicode
-				if i+5 > len(s) {
-					return "", JsonFormatError
-				}
-				sub_str := s[i+1 : i+5]
-				unicode, err := strconv.ParseInt(sub_str, 16, 32)
-				if err != nil {
-					// err
-					// log.Error("err: %s", err.Error())
-					return "", err
-				} else {
-					skip = 4
-					b.WriteRune(rune(unicode))
+	b.Grow(len(input))
+
+	for i := 0; i < len(input); {
+		chr := input[i]
+		if chr >= utf8.RuneSelf {
+			size, err := writeUTF8(&b, input[i:], opt)
+			if err != nil {
+				return "", &escapeError{i, err.Error()}
+			}
+			i += size
+			continue
+		}
+		if chr != '\\' {
+			b.WriteByte(chr)
+			i++
+			continue
+		}
+		if i+1 == len(input) {
+			// a trailing \ is just a simple character
+			b.WriteByte('\\')
+			break
+		}
+
+		esc := input[i+1]
+		if esc == '\'' && opt.Relaxed {
+			// escaped quote in a single-quoted string
+			b.WriteByte('\'')
+			i += 2
+			continue
+		}
+		if write_chr, exist := escapeMap[esc]; exist {
+			b.WriteByte(write_chr)
+			i += 2
+			continue
+		}
+		if esc != 'u' {
+			if opt.Strict {
+				return "", &escapeError{i, fmt.Sprintf("invalid escape %q", input[i:i+2])}
+			}
+			// the \ is just a simple character
+			b.WriteByte('\\')
+			i++
+			continue
+		}
+
+		// parse unicode, UTF-16 surrogate pairs are made of two escapes
+		r, err := unicodeFromEscape(input, i)
+		if err != nil {
+			return "", err
+		}
+		if utf16.IsSurrogate(r) {
+			if r < 0xDC00 {
+				low, err := unicodeFromEscape(input, i+6)
+				if err == nil && low >= 0xDC00 && low <= 0xDFFF {
+					b.WriteRune(utf16.DecodeRune(r, low))
+					i += 12
+					continue
 				}
-			default:
-				// the previous \ is just a simple character
-				escaping = false
-				b.WriteRune('\\')
-				b.WriteRune(chr)
 			}
-		} else {
-			switch chr {
-			case '\\':
-				// get ready to escape
-				escaping = true
-			default:
-				b.WriteRune(chr)
+			if opt.Strict {
+				return "", &escapeError{i, "lone surrogate in unicode escape"}
 			}
+			r = utf8.RuneError
 		}
-	}
-	if escaping {
-		escaping = false
-		b.WriteRune('\\')
+		b.WriteRune(r)
+		i += 6
 	}
 	return b.String(), nil
 }
 
-// ====================
-// New() functions
+// unicodeFromEscape decodes the \uXXXX escape at input[i:]
+func unicodeFromEscape(input []byte, i int) (rune, error) {
+	if i+6 > len(input) || input[i] != '\\' || input[i+1] != 'u' {
+		return 0, &escapeError{i, "incomplete unicode escape"}
+	}
+	unicode, err := strconv.ParseUint(string(input[i+2:i+6]), 16, 16)
+	if err != nil {
+		return 0, &escapeError{i, fmt.Sprintf("invalid unicode escape %q", input[i:i+6])}
+	}
+	return rune(unicode), nil
+}
 
-func NewFromBytes(b []byte) (*JsonValue, error) {
-	return NewFromString(string(b))
+// writeUTF8 writes the multi-byte character at the beginning of input, applying the
+// invalid UTF-8 policy if it is malformed. It returns the number of bytes consumed.
+func writeUTF8(b *bytes.Buffer, input []byte, opt *ParseOptions) (int, error) {
+	r, size := utf8.DecodeRune(input)
+	if r != utf8.RuneError || size > 1 {
+		b.Write(input[:size])
+		return size, nil
+	}
+	switch opt.InvalidUTF8 {
+	case InvalidUTF8Reject:
+		return 0, fmt.Errorf("invalid UTF-8 byte 0x%02X", input[0])
+	case InvalidUTF8Replace:
+		b.WriteRune(utf8.RuneError)
+	default:
+		b.WriteByte(input[0])
+	}
+	return 1, nil
 }
 
-func NewFromString(s string) (*JsonValue, error) {
-	var obj *JsonValue
-	var err error
+// stringFromRawBytes applies the invalid UTF-8 policy to input without unescaping it
+func stringFromRawBytes(input []byte, opt *ParseOptions) (string, error) {
+	if opt.InvalidUTF8 == InvalidUTF8PassThrough || utf8.Valid(input) {
+		return string(input), nil
+	}
+	b := bytes.Buffer{}
+	b.Grow(len(input))
+	for i := 0; i < len(input); {
+		if input[i] < utf8.RuneSelf {
+			b.WriteByte(input[i])
+			i++
+			continue
+		}
+		size, err := writeUTF8(&b, input[i:], opt)
+		if err != nil {
+			return "", &escapeError{i, err.Error()}
+		}
+		i += size
+	}
+	return b.String(), nil
+}
 
-	// get first character
-	for index, chr := range s {
-		switch chr {
-		case ' ', '\r', '\n', '\t':
-			// continue
-		case '{':
-			obj = NewObject()
-			err = obj.parseObject([]byte(s[index:]))
-			if err != nil {
-				return nil, err
-			} else {
-				return obj, nil
-			}
-		case '[':
-			obj = NewArray()
-			err = obj.parseArray([]byte(s[index:]))
-			if err != nil {
-				return nil, err
-			} else {
-				return obj, nil
-			}
-		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '-':
-			obj = new(JsonValue)
-			obj.valueType = Number
-			obj.mustSigned = (chr == '-')
-			obj.floatValue, err = strconv.ParseFloat(s[index:], 64)
-			if err != nil {
-				return nil, err
-			}
-			obj.intValue, err = strconv.ParseInt(s[index:], 10, 64)
-			if err != nil {
-				// return nil, err
-				// if parseFloat OK but parseInt failed, this may be a float
-				// log.Debug("error: %s", err.Error())
-				if strings.HasSuffix(err.Error(), "value out of range") {
-					// this must be a unsigned integer
-					obj.mustUnsigned = true
-				} else {
-					// log.Debug("MARK")
-					obj.mustFloat = true
-					obj.intValue = int64(obj.floatValue)
-					obj.uintValue = uint64(obj.intValue)
-				}
-			}
-			// log.Debug("mustUnsigned = %t, mustFloat = %t", obj.mustUnsigned, obj.mustFloat)
+// setChild sets a member of an object. An existing key keeps its place in the order.
+func (obj *JsonValue) setChild(key string, child *JsonValue) {
+	if _, exist := obj.objChildren[key]; false == exist {
+		obj.objKeys = append(obj.objKeys, key)
+	}
+	obj.objChildren[key] = child
+}
 
-			if obj.mustUnsigned && false == obj.mustFloat {
-				obj.uintValue, err = strconv.ParseUint(s[index:], 10, 64)
-				if err != nil {
-					return nil, err
-				}
-				if 0 != obj.uintValue&0x1000000000000000 {
-					obj.mustUnsigned = true
-				}
-			} else {
-				obj.uintValue = uint64(obj.intValue)
-			}
-			// log.Debug("orig str: %s", s[index:])
-			// log.Debug("parsed i: %d", obj.intValue)
-			// log.Debug("parsed u: %s", strconv.FormatUint(obj.uintValue, 10))
-			// log.Debug("parsed x: 0x%x", obj.uintValue)
-			return obj, nil
-		case '"':
-			obj = new(JsonValue)
-			obj.valueType = String
-			// search for next quote
-			next := strings.IndexRune(s[index+1:], '"')
-			if next < 0 {
-				return nil, JsonFormatError
-			} else {
-				obj.stringValue = s[index+1 : index+1+next]
-				return obj, nil
-			}
-		case 't':
-			if s[index:] == "true" {
-				obj = new(JsonValue)
-				obj.valueType = Boolean
-				obj.boolValue = true
-				return obj, nil
-			} else {
-				return nil, JsonFormatError
-			}
-		case 'f':
-			if s[index:] == "false" {
-				obj = new(JsonValue)
-				obj.valueType = Boolean
-				obj.boolValue = false
-				return obj, nil
-			} else {
-				return nil, JsonFormatError
-			}
-		case 'n':
-			if s[index:] == "null" {
-				obj = new(JsonValue)
-				obj.valueType = Null
-				return obj, nil
-			} else {
-				return nil, JsonFormatError
-			}
-		default:
-			// log.Debug("Skip: %c", chr)
-			// skip
+// deleteChild removes a member of an object. While an ObjectForeach is running over the
+// key list, the list is copied rather than shifted so that the iteration is not disturbed.
+func (obj *JsonValue) deleteChild(key string) {
+	delete(obj.objChildren, key)
+	for i, k := range obj.objKeys {
+		if k != key {
+			continue
+		}
+		if obj.foreach > 0 {
+			keys := make([]string, 0, len(obj.objKeys)-1)
+			keys = append(keys, obj.objKeys[:i]...)
+			obj.objKeys = append(keys, obj.objKeys[i+1:]...)
+		} else {
+			copy(obj.objKeys[i:], obj.objKeys[i+1:])
+			obj.objKeys[len(obj.objKeys)-1] = ""
+			obj.objKeys = obj.objKeys[:len(obj.objKeys)-1]
 		}
+		return
 	}
-	return nil, JsonFormatError
 }
 
 // ====================
-// parse functions
+// New() functions
 
-func (obj *JsonValue) parseObject(data []byte) error {
-	add_child := func(obj *JsonValue, key []byte, child *JsonValue) error {
-		key_str, key_err := stringFromEscapedBytes(key)
-		if key_err == nil {
-			obj.objChildren[key_str] = child
-		}
-		return key_err
+func NewFromBytes(b []byte, opts ...ParseOptions) (*JsonValue, error) {
+	opt := getParseOptions(opts)
+	b, err := toUTF8(b, opt)
+	if err != nil {
+		return nil, err
 	}
+	if opt.PreserveFormat {
+		// the parsed value keeps referring to the input
+		b = append([]byte{}, b...)
+	}
+	return newParser(b, opt).parseDocument()
+}
 
-	err := jsonparser.ObjectEach(data, func(key []byte, value []byte, dataType jsonparser.ValueType, _ int) error {
-		// log.Debug("----------")
-		// log.Debug("key: %s", string(key))
-		// log.Debug("value: %s", string(value))
-		switch dataType {
-		case jsonparser.String:
-			// log.Debug("string")
-			str_value, err := stringFromEscapedBytes(value)
-			if err != nil {
-				return err
-			}
-			child := NewString(str_value)
-			err = add_child(obj, key, child)
-			if err != nil {
-				return err
-			}
-		case jsonparser.Number:
-			// log.Debug("number")
-			var err error
-			child := new(JsonValue)
-			str_value := string(value)
-			child.valueType = Number
-
-			if strings.Contains(str_value, ".") {
-				child.floatValue, err = strconv.ParseFloat(str_value, 64)
-				if err != nil {
-					return err
-				}
-				child.intValue = int64(child.floatValue)
-			} else {
-				child.intValue, err = strconv.ParseInt(str_value, 10, 64)
-				if err != nil {
-					return err
-				}
-				child.floatValue = float64(child.intValue)
-			}
-			err = add_child(obj, key, child)
-			if err != nil {
-				return err
-			}
+func NewFromString(s string, opts ...ParseOptions) (*JsonValue, error) {
+	return NewFromBytes([]byte(s), opts...)
+}
 
-		case jsonparser.Object:
-			// log.Debug("object")
-			child := NewObject()
-			err := child.parseObject(value)
-			if err != nil {
-				// log.Error("Failed to parse object: %s", err.Error())
-				return err
-			}
-			// log.Debug("%s ---- object size: %d", string(key), child.Length())
-			err = add_child(obj, key, child)
-			if err != nil {
-				return err
-			}
-		case jsonparser.Array:
-			// log.Debug("array")
-			child := NewArray()
-			err := child.parseArray(value)
-			if err != nil {
-				// log.Error("Failed to parse array: %s", err.Error())
-				return err
-			}
-			// log.Debug("%s ---- array size: %d", string(key), child.Length())
-			err = add_child(obj, key, child)
-			if err != nil {
-				return err
-			}
-		case jsonparser.Boolean:
-			// log.Debug("bool")
-			b, err := strconv.ParseBool(string(value))
-			if err != nil {
-				return err
-			}
-			child := NewBool(b)
-			err = add_child(obj, key, child)
-			if err != nil {
-				return err
-			}
-		case jsonparser.Null:
-			// log.Debug("null")
-			child := NewNull()
-			err := add_child(obj, key, child)
-			if err != nil {
-				return err
-			}
-		default:
-			msg := fmt.Sprintf("Invalid type: %d", int(dataType))
-			// log.Debug("Invalid type: %d", int(dataType))
-			return errors.New(msg)
-		}
-		return nil
-	})
-	return err
+// parseNumberValue converts a number literal into a Number value
+func parseNumberValue(s string) (*JsonValue, error) {
+	obj := new(JsonValue)
+	if err := setNumberValue(obj, s); err != nil {
+		return nil, err
+	}
+	return obj, nil
 }
 
-func (obj *JsonValue) parseArray(data []byte) (err error) {
-	// check ending quote
-	{
-		l := len(data)
-		if l < 2 {
-			return JsonFormatError
-		}
-		if data[l-1] != byte(']') {
-			return JsonFormatError
-		}
+// setNumberValue makes obj the Number value of a number literal
+func setNumberValue(obj *JsonValue, s string) error {
+	if relaxed := parseRelaxedNumberValue(s); relaxed != nil {
+		*obj = *relaxed
+		return nil
 	}
-	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, _ int, _ error) {
-		switch dataType {
-		case jsonparser.String:
-			str_value, loc_err := stringFromEscapedBytes(value)
-			if loc_err != nil {
-				err = loc_err
-				return
-			}
-			child := NewString(str_value)
-			obj.arrChildren = append(obj.arrChildren, child)
 
-		case jsonparser.Number:
-			child := new(JsonValue)
-			str_value := string(value)
-			child.valueType = Number
+	var err error
+	obj.valueType = Number
+	obj.mustSigned = strings.HasPrefix(s, "-")
+	obj.floatValue, err = strconv.ParseFloat(s, 64)
+	if err != nil && false == strings.HasSuffix(err.Error(), "value out of range") {
+		// overflowing numbers are kept as infinity besides their literal
+		return err
+	}
+	obj.intValue, err = strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		// if parseFloat OK but parseInt failed, this may be a float
+		if strings.HasSuffix(err.Error(), "value out of range") {
+			// this must be a unsigned integer
+			obj.mustUnsigned = true
+		} else {
+			obj.mustFloat = true
+			obj.intValue = int64(obj.floatValue)
+			obj.uintValue = uint64(obj.intValue)
+		}
+	}
 
-			if strings.Contains(str_value, ".") {
-				child.floatValue, err = strconv.ParseFloat(str_value, 64)
-				if err != nil {
-					return
-				}
-				child.intValue = int64(child.floatValue)
-			} else {
-				child.intValue, err = strconv.ParseInt(str_value, 10, 64)
-				if err != nil {
-					return
-				}
-				child.floatValue = float64(child.intValue)
-			}
-			obj.arrChildren = append(obj.arrChildren, child)
+	if obj.mustUnsigned && false == obj.mustFloat {
+		obj.uintValue, err = strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			// an integer beyond 64 bits, only the literal keeps its exact value
+			obj.mustUnsigned = false
+			obj.mustFloat = true
+		} else if 0 != obj.uintValue&0x1000000000000000 {
+			obj.mustUnsigned = true
+		}
+	} else {
+		obj.uintValue = uint64(obj.intValue)
+	}
+	if checkNumber([]byte(s)) < 0 {
+		obj.literal = s
+	}
+	return nil
+}
 
-		case jsonparser.Object:
-			child := NewObject()
-			err = child.parseObject(value)
-			if err != nil {
-				return
-			}
-			obj.arrChildren = append(obj.arrChildren, child)
-		case jsonparser.Array:
-			child := NewArray()
-			err = child.parseArray(value)
-			if err != nil {
-				return
-			}
-			obj.arrChildren = append(obj.arrChildren, child)
-		case jsonparser.Boolean:
-			b, loc_err := strconv.ParseBool(string(value))
-			if loc_err != nil {
-				err = loc_err
-				return
-			}
-			child := NewBool(b)
-			obj.arrChildren = append(obj.arrChildren, child)
-		case jsonparser.Null:
-			child := NewNull()
-			obj.arrChildren = append(obj.arrChildren, child)
-		default:
-			msg := fmt.Sprintf("Invalid type: %d", int(dataType))
-			// log.Debug("Invalid type: %d", int(dataType))
-			err = errors.New(msg)
-			return
+// parseRelaxedNumberValue converts hexadecimal numbers, Infinity and NaN, or returns nil
+// for any other literal
+func parseRelaxedNumberValue(s string) *JsonValue {
+	unsigned := strings.TrimLeft(s, "+-")
+	switch {
+	case unsigned == "Infinity":
+		if strings.HasPrefix(s, "-") {
+			return NewFloat(math.Inf(-1))
 		}
-		return
-	})
-	return
+		return NewFloat(math.Inf(1))
+	case unsigned == "NaN":
+		return NewFloat(math.NaN())
+	case strings.HasPrefix(unsigned, "0x"), strings.HasPrefix(unsigned, "0X"):
+		if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return NewInt64(i)
+		}
+		if u, err := strconv.ParseUint(strings.TrimPrefix(s, "+"), 0, 64); err == nil {
+			return NewUint64(u)
+		}
+	}
+	return nil
 }
 
 // ====================
@@ -489,6 +407,7 @@ func (obj *JsonValue) Boolean() bool {
 }
 
 func (obj *JsonValue) Length() int {
+	obj.expand()
 	if obj.valueType == Array {
 		return len(obj.arrChildren)
 	} else if obj.valueType == Object {
@@ -499,6 +418,7 @@ func (obj *JsonValue) Length() int {
 }
 
 func (obj *JsonValue) Len() int {
+	obj.expand()
 	if obj.valueType == Array {
 		return len(obj.arrChildren)
 	} else if obj.valueType == Object {
@@ -508,6 +428,12 @@ func (obj *JsonValue) Len() int {
 	}
 }
 
+// Position returns where the value was found in the parsed text. Values which were
+// created or set programmatically have an invalid Position.
+func (obj *JsonValue) Position() Position {
+	return obj.pos
+}
+
 // types
 func (obj *JsonValue) Type() ValueType {
 	return ValueType(obj.valueType)
@@ -568,6 +494,9 @@ func (obj *JsonValue) GetByKey(keys ...string) (*JsonValue, error) {
 	if 0 == len(keys) {
 		return obj, nil
 	}
+	if err := obj.expand(); err != nil {
+		return nil, err
+	}
 	if 1 == len(keys) {
 		child, exist := obj.objChildren[keys[0]]
 		if false == exist {
@@ -590,6 +519,9 @@ func (obj *JsonValue) GetByKey(keys ...string) (*JsonValue, error) {
 }
 
 func (obj *JsonValue) GetAtIndex(index int) (*JsonValue, error) {
+	if err := obj.expand(); err != nil {
+		return nil, err
+	}
 	if obj.valueType == Array {
 		if index >= 0 && index < obj.Length() {
 			return obj.arrChildren[index], nil
@@ -664,23 +596,51 @@ func (obj *JsonValue) Marshal(opts ...Option) ([]byte, error) {
 	return buff.Bytes(), nil
 }
 
-func (obj *JsonValue) marshalToBuffer(buff *bytes.Buffer, opts ...Option) error {
+func (obj *JsonValue) marshalToBuffer(buff marshalWriter, opts ...Option) error {
 	var opt *Option
 	if len(opts) > 0 {
 		opt = &(opts[0])
 	} else {
 		opt = &dftOption
 	}
+	path, err := newMarshalPath(opt)
+	if err != nil {
+		return err
+	}
+	return obj.marshalValue(buff, opt, 0, path)
+}
+
+// marshalValue writes a value which is depth levels deep in the marshaled document
+func (obj *JsonValue) marshalValue(buff marshalWriter, opt *Option, depth int, path *marshalPath) error {
+	if opt.PreserveFormat && obj.layout != nil {
+		return obj.marshalLayout(buff, opt)
+	}
+	if obj.lazy != nil {
+		// the raw text is neither indented nor escaped as configured
+		if raw := obj.lazy.raw(); raw != nil && false == opt.indented() && false == opt.escapesMore() {
+			buff.Write(raw)
+			return nil
+		}
+		if err := obj.expand(); err != nil {
+			return err
+		}
+	}
 
 	switch obj.valueType {
 	case String:
-		s := `"` + escapeJsonString(obj.String(), true) + `"`
+		s := `"` + escapeJsonString(obj.String(), opt) + `"`
 		buff.WriteString(s)
 		return nil
 	case Number:
 		i := obj.intValue
 		f := obj.floatValue
-		if obj.mustFloat {
+		if obj.literal != "" && (0 == opt.FloatDigits || math.IsInf(f, 0)) {
+			buff.WriteString(obj.literal)
+			return nil
+		} else if math.IsNaN(f) || math.IsInf(f, 0) {
+			// NaN and Infinity, e.g. from relaxed input, have no JSON representation
+			return JsonTypeError
+		} else if obj.mustFloat {
 			s := convertFloatToString(f, opt.FloatDigits)
 			buff.WriteString(s)
 			return nil
@@ -711,39 +671,65 @@ func (obj *JsonValue) marshalToBuffer(buff *bytes.Buffer, opts ...Option) error
 	case Object:
 		is_first := true
 		buff.WriteRune('{')
-		marshal_child_func := func(key string, child *JsonValue) {
+		marshal_child_func := func(key string, child *JsonValue) error {
 			if child.IsNull() && false == opt.ShowNull {
 				// do nothing
+				return nil
 			} else {
 				if is_first {
 					is_first = false
 				} else {
 					buff.WriteRune(',')
 				}
+				opt.newline(buff, depth+1)
 				buff.WriteRune('"')
-				buff.WriteString(escapeJsonString(key, true))
+				buff.WriteString(escapeJsonString(key, opt))
 				buff.WriteRune('"')
 				buff.WriteRune(':')
+				if opt.indented() {
+					buff.WriteRune(' ')
+				}
 
-				child.marshalToBuffer(buff, *opt)
+				path.push(key)
+				err := child.marshalValue(buff, opt, depth+1, path)
+				path.pop()
+				if err != nil {
+					return err
+				}
+				// stop early if the output has failed
+				return writeError(buff)
 			}
 		}
-		if Random != opt.SortMode {
-			sorted := sortObjects(obj, opt.SortMode)
+		less := path.keyOrder()
+		if nil == less {
+			less = opt.KeyOrder
+		}
+		if nil == less {
+			less = opt.SortMode.keyOrder()
+		}
+		if less != nil {
+			sorted := sortObjects(obj, less)
 			for _, pair := range sorted {
-				marshal_child_func(pair.K, pair.V)
+				if err := marshal_child_func(pair.K, pair.V); err != nil {
+					return err
+				}
 			}
 		} else {
-			for key, child := range obj.objChildren {
-				marshal_child_func(key, child)
+			for _, key := range obj.objKeys {
+				if err := marshal_child_func(key, obj.objChildren[key]); err != nil {
+					return err
+				}
 			}
 		}
+		if false == is_first {
+			opt.newline(buff, depth)
+		}
 		buff.WriteRune('}')
 		return nil
 	case Array:
 		is_first := true
 		buff.WriteRune('[')
-		for _, child := range obj.arrChildren {
+		for i, child := range obj.arrChildren {
 			if child.IsNull() && false == opt.ShowNull {
 				// do nothing
 			} else {
@@ -752,9 +738,21 @@ func (obj *JsonValue) marshalToBuffer(buff *bytes.Buffer, opts ...Option) error
 				} else {
 					buff.WriteRune(',')
 				}
-				child.marshalToBuffer(buff, *opt)
+				opt.newline(buff, depth+1)
+				path.push(i)
+				err := child.marshalValue(buff, opt, depth+1, path)
+				path.pop()
+				if err != nil {
+					return err
+				}
+				if err = writeError(buff); err != nil {
+					return err
+				}
 			}
 		}
+		if false == is_first {
+			opt.newline(buff, depth)
+		}
 		buff.WriteRune(']')
 		return nil
 	default:
@@ -784,12 +782,16 @@ func (obj *JsonValue) Delete(first interface{}, keys ...interface{}) error {
 	default:
 		last_index := len(keys) - 1
 		last_key = &keys[last_index]
-		parent, err = obj.Get(first, keys[1:]...)
+		parent, err = obj.Get(first, keys[:last_index]...)
 		if err != nil {
 			return ObjectNotFoundError
 		}
 	}
 
+	if err = parent.expand(); err != nil {
+		return err
+	}
+
 	// get child
 	switch (*last_key).(type) {
 	case string:
@@ -804,17 +806,18 @@ func (obj *JsonValue) Delete(first interface{}, keys ...interface{}) error {
 			// log.Debug("key %s not found", last_key_str)
 			return err
 		}
-		delete(parent.objChildren, last_key_str)
+		parent.deleteChild(last_key_str)
 		return nil
 
 	case uint8, int8, uint16, int16, uint32, int32, uint64, int64, int, uint:
-		value := reflect.ValueOf(first)
+		value := reflect.ValueOf(*last_key)
 		index := int(value.Int())
 		arr_len := len(parent.arrChildren)
 		if index >= 0 && index < arr_len {
 			tail := parent.arrChildren[index+1:]
 			parent.arrChildren = parent.arrChildren[0:index]
 			parent.arrChildren = append(parent.arrChildren, tail...)
+			parent.layoutDelete(index)
 			return nil
 		} else {
 			return IndexOutOfBoundsError
@@ -830,6 +833,9 @@ func (this *JsonValue) Append(newOne *JsonValue, keys ...interface{}) (*JsonValu
 		return nil, ParaError
 	}
 	if 0 == len(keys) {
+		if err := this.expand(); err != nil {
+			return nil, err
+		}
 		if this.valueType == Array {
 			this.arrChildren = append(this.arrChildren, newOne)
 			return newOne, nil
@@ -855,6 +861,9 @@ func (this *JsonValue) Insert(newOne *JsonValue, index interface{}, keys ...inte
 		if this.valueType != Array {
 			return nil, NotAnArrayError
 		}
+		if err := this.expand(); err != nil {
+			return nil, err
+		}
 		switch index.(type) {
 		case uint8, int8, uint16, int16, uint32, int32, uint64, int64, int, uint:
 			value := reflect.ValueOf(index)
@@ -864,6 +873,7 @@ func (this *JsonValue) Insert(newOne *JsonValue, index interface{}, keys ...inte
 				// ref: [SliceTricks](https://github.com/golang/go/wiki/SliceTricks)
 				a := this.arrChildren
 				this.arrChildren = append(a[:index], append([]*JsonValue{newOne}, a[index:]...)...)
+				this.layoutInsert(index)
 				return newOne, nil
 			} else {
 				return nil, IndexOutOfBoundsError
@@ -891,6 +901,9 @@ func (this *JsonValue) Swap(i, j int) error {
 	if false == this.IsArray() {
 		return NotAnArrayError
 	}
+	if err := this.expand(); err != nil {
+		return err
+	}
 
 	l := this.Length()
 	if i >= l || j >= l {
@@ -898,6 +911,7 @@ func (this *JsonValue) Swap(i, j int) error {
 	}
 
 	this.arrChildren[i], this.arrChildren[j] = this.arrChildren[j], this.arrChildren[i]
+	this.layoutSwap(i, j)
 	return nil
 }
 
@@ -906,11 +920,15 @@ func (this *JsonValue) Set(newOne *JsonValue, first interface{}, keys ...interfa
 	keys_count := len(keys)
 	switch keys_count {
 	case 0:
+		if err := this.expand(); err != nil {
+			return nil, err
+		}
 		switch first.(type) {
 		case string:
 			if this.IsObject() {
 				key := first.(string)
-				this.objChildren[key] = newOne
+				this.setChild(key, newOne)
+				this.layoutAddKey(key)
 				return newOne, nil
 			} else {
 				// log.Error("Not an object")
@@ -954,6 +972,9 @@ func (this *JsonValue) ArrayForeach(callback func(index int, value *JsonValue) e
 		// log.Error("object is not an array")
 		return NotAnArrayError
 	}
+	if err := this.expand(); err != nil {
+		return err
+	}
 	// log.Debug("array size: %d", len(this.arrChildren))
 	for i, val := range this.arrChildren {
 		err := callback(i, val)
@@ -968,7 +989,19 @@ func (this *JsonValue) ObjectForeach(callback func(key string, value *JsonValue)
 	if false == this.IsObject() {
 		return NotAnObjectError
 	}
-	for k, v := range this.objChildren {
+	if err := this.expand(); err != nil {
+		return err
+	}
+	this.foreach++
+	defer func() {
+		this.foreach--
+	}()
+	for _, k := range this.objKeys {
+		v, exist := this.objChildren[k]
+		if false == exist {
+			// deleted by the callback
+			continue
+		}
 		err := callback(k, v)
 		if err != nil {
 			return err
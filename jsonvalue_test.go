@@ -1,6 +1,10 @@
 package jsonconv
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
 
 const raw = `{
 	"a-string": "这是一个string",
@@ -43,6 +47,35 @@ func TestNewFromBytes(t *testing.T) {
 	}
 }
 
+func TestDelete(t *testing.T) {
+	o, err := NewFromString(`{"a": {"x": {"c": 1}, "b": {"c": 2, "d": 3}}, "arr": [0, 1, 2]}`)
+	if err != nil {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+
+	// the parent of a nested key is found with all keys but the last one
+	if err = o.Delete("a", "b", "c"); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if _, err = o.Get("a", "b", "c"); err == nil {
+		t.Errorf("a.b.c not deleted")
+	}
+	if _, err = o.Get("a", "x", "c"); err != nil {
+		t.Errorf("a.x.c deleted: %v", err)
+	}
+
+	// the index is the last key
+	if err = o.Delete("arr", 1); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	arr, _ := o.Get("arr")
+	if arr.Length() != 2 || arr.arrChildren[0].Int() != 0 || arr.arrChildren[1].Int() != 2 {
+		s, _ := arr.MarshalToString()
+		t.Errorf("unexpected array %s", s)
+	}
+}
+
 func TestNewFromString(t *testing.T) {
 	// normal object
 	o, err := NewFromString(raw)
@@ -65,7 +98,7 @@ func TestNewFromString(t *testing.T) {
 	// normal string
 	var s string
 	s = "\\g\\"
-	a, err = NewFromString(`"` + s + `"`)
+	a, err = NewFromString(`"\g\\"`)
 	if err != nil || a == nil {
 		t.Error("NewFromString failed")
 	}
@@ -114,3 +147,153 @@ func TestNewFromString(t *testing.T) {
 	func_test_err(`{"string}`)
 	func_test_err(`[{"string}]`)
 }
+
+func TestMarshalIndent(t *testing.T) {
+	o, err := NewFromString(`{"b": [1, {"c": null}, []], "a": {}, "d": "x"}`)
+	if err != nil {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+	s, err := o.MarshalToString(Option{SortMode: DictAsc, ShowNull: true, Indent: "  ", Prefix: "> "})
+	if err != nil {
+		t.Errorf("MarshalToString failed: %v", err)
+		return
+	}
+	compact, _ := o.MarshalToString(Option{SortMode: DictAsc, ShowNull: true})
+	expected := bytes.Buffer{}
+	json.Indent(&expected, []byte(compact), "> ", "  ")
+	if s != expected.String() {
+		t.Errorf("unexpected result:\n%s\nexpected:\n%s", s, expected.String())
+	}
+
+	s, _ = o.MarshalToString(Option{SortMode: DictAsc, Indent: "\t", Newline: "\r\n"})
+	if s != "{\r\n\t\"a\": {},\r\n\t\"b\": [\r\n\t\t1,\r\n\t\t{},\r\n\t\t[]\r\n\t],\r\n\t\"d\": \"x\"\r\n}" {
+		t.Errorf("unexpected result %q", s)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	o := NewObject()
+	o.Set(NewString("中文 <a href='http://x/?q=1&p=100%'>\u2028"), "网址/</")
+
+	s, _ := o.MarshalToString()
+	if exp := "{\"网址/</\":\"中文 <a href='http://x/?q=1&p=100%'>\u2028\"}"; s != exp {
+		t.Errorf("%s != %s", s, exp)
+	}
+	s, _ = o.MarshalToString(Option{EscapeHTML: true, EscapeSlash: true, JavaScriptSafe: true})
+	if exp := `{"网址\/\u003c\/":"中文 \u003ca href='http:\/\/x\/?q=1\u0026p=100%'\u003e\u2028"}`; s != exp {
+		t.Errorf("%s != %s", s, exp)
+	}
+	s, _ = o.MarshalToString(Option{EnsureASCII: true})
+	if exp := `{"\u7f51\u5740/</":"\u4e2d\u6587 <a href='http://x/?q=1&p=100%'>\u2028"}`; s != exp {
+		t.Errorf("%s != %s", s, exp)
+	}
+
+	// lazy containers which have never been accessed are escaped as well
+	lazy, _ := NewLazyFromBytes([]byte(`{"a": {"k": "中文/x<"}, "b": "中"}`))
+	s, _ = lazy.MarshalToString(Option{EnsureASCII: true, EscapeSlash: true, EscapeHTML: true})
+	if exp := `{"a":{"k":"\u4e2d\u6587\/x\u003c"},"b":"\u4e2d"}`; s != exp {
+		t.Errorf("%s != %s", s, exp)
+	}
+}
+
+func TestObjectOrder(t *testing.T) {
+	s := `{"z": 1, "a": {"y": true, "b": null, "x": []}, "m": 3}`
+	o, _ := NewFromString(s)
+	o.Set(NewInt(2), "c")
+	o.Set(NewInt(0), "z")
+	o.Delete("m")
+	o.Set(NewInt(3), "m")
+
+	expected := `{"z":0,"a":{"y":true,"x":[]},"c":2,"m":3}`
+	for i := 0; i < 5; i++ {
+		if res, _ := o.MarshalToString(); res != expected {
+			t.Errorf("%s != %s", res, expected)
+			return
+		}
+	}
+
+	keys := ""
+	o.ObjectForeach(func(key string, value *JsonValue) error {
+		keys += key
+		if key == "z" {
+			o.Delete("a")
+		}
+		return nil
+	})
+	if keys != "zcm" {
+		t.Errorf("unexpected keys %s", keys)
+	}
+
+	// deleting outside ObjectForeach shifts the keys in place
+	o, _ = NewFromString(`{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5}`)
+	o.Delete("b")
+	o.Delete("e")
+	o.Set(NewInt(6), "b")
+	o.Delete("a")
+	if res, _ := o.MarshalToString(); res != `{"c":3,"d":4,"b":6}` {
+		t.Errorf("unexpected result %s", res)
+	}
+
+	a := NewArena()
+	v, _ := a.ParseString(s)
+	if res, _ := v.MarshalToString(Option{ShowNull: true}); res != `{"z":1,"a":{"y":true,"b":null,"x":[]},"m":3}` {
+		t.Errorf("unexpected result %s", res)
+	}
+}
+
+func TestKeyOrder(t *testing.T) {
+	o, _ := NewFromString(`{"item10": 1, "Name": "x", "item2": 2, "id": 3, "apple": 4, "items": [{"b": 1, "id": 2, "a": 3}]}`)
+
+	s, _ := o.MarshalToString(Option{KeyOrder: NaturalOrder, PathKeyOrder: map[string]KeyOrder{"items[*]": DictOrder}})
+	if exp := `{"Name":"x","apple":4,"id":3,"item2":2,"item10":1,"items":[{"a":3,"b":1,"id":2}]}`; s != exp {
+		t.Errorf("%s != %s", s, exp)
+	}
+	s, _ = o.MarshalToString(Option{KeyOrder: CaseInsensitiveOrder, PathKeyOrder: map[string]KeyOrder{"items[0]": PriorityOrder([]string{"id"}, nil)}})
+	if exp := `{"apple":4,"id":3,"item10":1,"item2":2,"items":[{"id":2,"a":3,"b":1}],"Name":"x"}`; s != exp {
+		t.Errorf("%s != %s", s, exp)
+	}
+	s, _ = o.MarshalToString(Option{SortMode: DictDesc, KeyOrder: PriorityOrder([]string{"id", "Name"}, NaturalOrder)})
+	if exp := `{"id":3,"Name":"x","apple":4,"item2":2,"item10":1,"items":[{"id":2,"a":3,"b":1}]}`; s != exp {
+		t.Errorf("%s != %s", s, exp)
+	}
+	if _, err := o.MarshalToString(Option{PathKeyOrder: map[string]KeyOrder{"items[x]": DictOrder}}); err != PathFormatError {
+		t.Errorf("PathFormatError expected, got %v", err)
+	}
+}
+
+func TestPathKeyOrderPrecedence(t *testing.T) {
+	o, _ := NewFromString(`{"a": {"b": {"x": 2, "y": 1}}}`)
+	opt := Option{PathKeyOrder: map[string]KeyOrder{
+		"a.*": DictOrder,
+		"*.b": func(a, b string) bool { return a > b },
+	}}
+	for i := 0; i < 20; i++ {
+		if s, _ := o.MarshalToString(opt); s != `{"a":{"b":{"y":1,"x":2}}}` {
+			t.Errorf("unexpected result %s", s)
+			return
+		}
+	}
+}
+
+func TestMergeOverrideObject(t *testing.T) {
+	to, _ := NewFromString(`{"x": 0}`)
+	from, _ := NewFromString(`{"a": 1}`)
+	to.MergeFrom(from, Option{OverrideObject: true})
+	to.SetInt(2, "b")
+
+	if from.Len() != 1 {
+		t.Errorf("bad length %d", from.Len())
+	}
+	keys := ""
+	from.ObjectForeach(func(key string, value *JsonValue) error {
+		keys += key
+		return nil
+	})
+	if res, _ := from.MarshalToString(); res != `{"a":1}` || keys != "a" {
+		t.Errorf("source modified: %s, keys %s", res, keys)
+	}
+	if res, _ := to.MarshalToString(); res != `{"a":1,"b":2}` {
+		t.Errorf("unexpected result %s", res)
+	}
+}
@@ -0,0 +1,100 @@
+package jsonconv
+
+// lazySource is the unparsed text of an object or array created by NewLazyFromBytes
+type lazySource struct {
+	src        []byte
+	start, end int
+	opt        *ParseOptions
+	depth      int // nesting depth of the container, for MaxDepth
+}
+
+// NewLazyFromBytes parses only the top level of a document. Nested objects and arrays are
+// checked for syntax errors but their members are built the first time they are accessed,
+// e.g. through Get, ObjectForeach or ArrayForeach. Errors which can only be found while
+// building them, such as duplicate keys with DuplicateError, are returned by that access.
+//
+// Marshal copies containers which have never been accessed from the input as they are,
+// so Option settings such as ShowNull and SortMode do not apply to them. This is not done
+// when OnKey, OnValue, a DuplicateKeys policy or an InvalidUTF8 policy which changes the
+// parsed values is set, since the copied text would not reflect them, nor when marshaling
+// with indentation or with escaping beyond what RFC 8259 requires.
+//
+// The returned value refers to b, which must not be modified while it is in use.
+// ParseOptions.PreserveFormat is not supported.
+func NewLazyFromBytes(b []byte, opts ...ParseOptions) (*JsonValue, error) {
+	opt := getParseOptions(opts)
+	if opt.PreserveFormat {
+		o := *opt
+		o.PreserveFormat = false
+		opt = &o
+	}
+	b, err := toUTF8(b, opt)
+	if err != nil {
+		return nil, err
+	}
+	p := newParser(b, opt)
+	p.lazy = true
+	return p.parseDocument()
+}
+
+// parseLazy skips the container at the current position and returns it unparsed
+func (p *parser) parseLazy(t ValueType) (*JsonValue, error) {
+	start := p.pos
+	depth := p.depth
+	if err := p.skipValue(); err != nil {
+		return nil, err
+	}
+	v := p.newValue(t)
+	v.lazy = &lazySource{
+		src:   p.data,
+		start: start,
+		end:   p.pos,
+		opt:   p.opt,
+		depth: depth,
+	}
+	return v, nil
+}
+
+// expand builds the members of a lazy container, leaving nested containers lazy
+func (obj *JsonValue) expand() error {
+	lz := obj.lazy
+	if nil == lz {
+		return nil
+	}
+
+	p := newParser(lz.src, lz.opt)
+	p.lazy = true
+	p.lazyBase = lz.depth
+	p.depth = lz.depth
+	p.pos = lz.start
+	p.line = obj.pos.Line
+	p.lineStart = lz.start - obj.pos.Column + 1
+
+	v, err := p.parseBareValue()
+	if err != nil {
+		return err
+	}
+	obj.objChildren = v.objChildren
+	obj.objKeys = v.objKeys
+	obj.arrChildren = v.arrChildren
+	obj.lazy = nil
+	return nil
+}
+
+// raw returns the unparsed text of a lazy container, or nil if it cannot be written as it is
+func (lz *lazySource) raw() []byte {
+	opt := lz.opt
+	switch {
+	case opt.Relaxed:
+		// may contain comments and other extensions
+		return nil
+	case opt.OnKey != nil || opt.OnValue != nil:
+		// the hooks have to see every member
+		return nil
+	case opt.DuplicateKeys != DuplicateLastWins:
+		return nil
+	case opt.InvalidUTF8 == InvalidUTF8Replace || opt.InvalidUTF8 == InvalidUTF8Reject:
+		return nil
+	}
+	return lz.src[lz.start:lz.end]
+}
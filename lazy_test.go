@@ -0,0 +1,98 @@
+package jsonconv
+
+import (
+	"testing"
+)
+
+func TestLazy(t *testing.T) {
+	s := `{"id": 7, "event": {"type": "push", "repo": {"name": "x"}}, "commits": [{"sha": "a"}, {"sha": "b"}], "payload": {"k": [1, 2, 3]}}`
+	v, err := NewLazyFromBytes([]byte(s))
+	if err != nil {
+		t.Errorf("NewLazyFromBytes failed: %v", err)
+		return
+	}
+	event, _ := v.Get("event")
+	if nil == event.lazy {
+		t.Errorf("nested object parsed too early")
+	}
+	if name, _ := v.GetString("event", "repo", "name"); name != "x" {
+		t.Errorf("bad value %q", name)
+	}
+	if nil != event.lazy {
+		t.Errorf("object not expanded")
+	}
+
+	commits, _ := v.Get("commits")
+	n := 0
+	commits.ArrayForeach(func(i int, c *JsonValue) error {
+		n++
+		return nil
+	})
+	if n != 2 {
+		t.Errorf("bad array length %d", n)
+	}
+	if pos := commits.Position(); pos.Column != 72 {
+		t.Errorf("bad position %v", pos)
+	}
+	if sha, _ := v.Get("commits", 1, "sha"); sha.Position().Column != 95 {
+		t.Errorf("bad position %v", sha.Position())
+	}
+
+	// untouched members are copied as they are
+	v.SetInt(8, "id")
+	res, _ := v.MarshalToString(Option{SortMode: DictAsc})
+	if exp := `{"commits":[{"sha": "a"},{"sha":"b"}],"event":{"repo":{"name":"x"},"type":"push"},"id":8,"payload":{"k": [1, 2, 3]}}`; res != exp {
+		t.Errorf("unexpected output %s", res)
+	}
+
+	// syntax errors are found upfront, others on access
+	if _, err = NewLazyFromBytes([]byte(`{"a": {"b": [1, }}`)); err == nil {
+		t.Errorf("syntax error not detected")
+	}
+	v, err = NewLazyFromBytes([]byte(`{"a": {"b": 1, "b": 2}}`), ParseOptions{DuplicateKeys: DuplicateError})
+	if err != nil {
+		t.Errorf("NewLazyFromBytes failed: %v", err)
+		return
+	}
+	if _, err = v.Get("a", "b"); err == nil {
+		t.Errorf("duplicate key not detected")
+	}
+}
+
+func TestLazyLenAndErrors(t *testing.T) {
+	v, _ := NewLazyFromBytes([]byte(`{"a": {"x": 1, "y": 2}, "b": [1, 2, 3]}`))
+	a, _ := v.Get("a")
+	b, _ := v.Get("b")
+	if a.Len() != 2 || b.Len() != 3 {
+		t.Errorf("bad lengths %d, %d", a.Len(), b.Len())
+	}
+
+	v, _ = NewLazyFromBytes([]byte(`{"a": {"b": 1, "b": 2}}`), ParseOptions{DuplicateKeys: DuplicateError})
+	if res, err := v.MarshalToString(Option{Indent: " "}); err == nil {
+		t.Errorf("error not reported, got %q", res)
+	}
+}
+
+func TestLazyRawOptions(t *testing.T) {
+	b := []byte(`{"a": {"Secret": 1, "b": 2, "b": 3}, "c": ["` + "\xff" + `"]}`)
+	for i, c := range []struct {
+		opt ParseOptions
+		exp string
+	}{
+		{ParseOptions{}, `{"a":{"Secret": 1, "b": 2, "b": 3},"c":["` + "\xff" + `"]}`},
+		{ParseOptions{OnKey: func(key string) (string, bool, error) {
+			return key, key != "Secret", nil
+		}}, `{"a":{"b":3},"c":["` + "\uFFFD" + `"]}`},
+		{ParseOptions{DuplicateKeys: DuplicateFirstWins}, `{"a":{"Secret":1,"b":2},"c":["` + "\uFFFD" + `"]}`},
+		{ParseOptions{InvalidUTF8: InvalidUTF8Replace}, `{"a":{"Secret":1,"b":3},"c":["` + "\uFFFD" + `"]}`},
+	} {
+		v, err := NewLazyFromBytes(b, c.opt)
+		if err != nil {
+			t.Errorf("%d: NewLazyFromBytes failed: %v", i, err)
+			continue
+		}
+		if res, _ := v.MarshalToString(); res != c.exp {
+			t.Errorf("%d: %s != %s", i, res, c.exp)
+		}
+	}
+}
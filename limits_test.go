@@ -0,0 +1,77 @@
+package jsonconv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseLimits(t *testing.T) {
+	cases := []struct {
+		s   string
+		opt ParseOptions
+		err error
+	}{
+		{`[[[1]]]`, ParseOptions{MaxDepth: 2}, DepthLimitError},
+		{`{"a": {"b": {}}}`, ParseOptions{MaxDepth: 2}, DepthLimitError},
+		{`[1, 2, 3]`, ParseOptions{MaxBytes: 8}, SizeLimitError},
+		{`["abcd"]`, ParseOptions{MaxStringLength: 3}, StringLengthLimitError},
+		{`{"abcd": 1}`, ParseOptions{MaxStringLength: 3}, StringLengthLimitError},
+		{`"abcd"`, ParseOptions{MaxStringLength: 3}, StringLengthLimitError},
+		{`[12345]`, ParseOptions{MaxNumberLength: 4}, NumberLengthLimitError},
+		{`{"a": 1, "b": 2, "c": 3}`, ParseOptions{MaxObjectKeys: 2}, ObjectKeysLimitError},
+		{`[1, [2, 3, 4]]`, ParseOptions{MaxArrayLength: 2}, ArrayLengthLimitError},
+	}
+	for _, c := range cases {
+		if _, err := NewFromString(c.s, c.opt); false == errors.Is(err, c.err) {
+			t.Errorf("%s: %v expected, got %v", c.s, c.err, err)
+		}
+		if _, err := NewDecoder(strings.NewReader(c.s), c.opt).Decode(); false == errors.Is(err, c.err) {
+			t.Errorf("Decoder %s: %v expected, got %v", c.s, c.err, err)
+		}
+		tk := NewTokenizer(strings.NewReader(c.s), c.opt)
+		var err error
+		for err == nil {
+			_, err = tk.Next()
+		}
+		if false == errors.Is(err, c.err) {
+			t.Errorf("Tokenizer %s: %v expected, got %v", c.s, c.err, err)
+		}
+
+		// within limits
+		if _, err := NewFromString(c.s); err != nil {
+			t.Errorf("%s: unexpected error %v", c.s, err)
+		}
+	}
+
+	// unquoted keys of relaxed mode
+	for _, s := range []string{`{abcd: 1}`, `{a: {abcd: 1}}`} {
+		opt := ParseOptions{Relaxed: true, MaxStringLength: 3}
+		if _, err := NewFromString(s, opt); false == errors.Is(err, StringLengthLimitError) {
+			t.Errorf("%s: StringLengthLimitError expected, got %v", s, err)
+		}
+		if _, err := NewLazyFromBytes([]byte(s), opt); false == errors.Is(err, StringLengthLimitError) {
+			t.Errorf("lazy %s: StringLengthLimitError expected, got %v", s, err)
+		}
+	}
+	if _, err := NewFromString(`{abc: 1}`, ParseOptions{Relaxed: true, MaxStringLength: 3}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// deep nesting does not overflow the stack
+	deep := strings.Repeat("[", 1000000)
+	if _, err := NewFromString(deep, ParseOptions{MaxDepth: 1000}); false == errors.Is(err, DepthLimitError) {
+		t.Errorf("DepthLimitError expected, got %v", err)
+	}
+
+	// MaxBytes applies to every document of a stream
+	d := NewDecoder(strings.NewReader("[1, 2]\n[3, 4]\n[5, 6, 7]"), ParseOptions{MaxBytes: 7})
+	for i := 0; i < 2; i++ {
+		if _, err := d.Decode(); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	if _, err := d.Decode(); false == errors.Is(err, SizeLimitError) {
+		t.Errorf("SizeLimitError expected, got %v", err)
+	}
+}
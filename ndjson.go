@@ -0,0 +1,133 @@
+package jsonconv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// LineError is the error of a single line of newline-delimited JSON
+type LineError struct {
+	Line int // 1-based line number in the input
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// NDJSONFunc receives the value parsed from one line, or a *LineError if the line could
+// not be parsed. Returning an error stops ParseNDJSON, which then returns that error.
+type NDJSONFunc func(line int, v *JsonValue, err error) error
+
+type ndjsonJob struct {
+	line  int
+	data  []byte
+	value *JsonValue
+	err   error
+	ready chan struct{}
+}
+
+// ParseNDJSON reads newline-delimited JSON from r and parses its lines on workers
+// goroutines, or on one per CPU if workers is not positive. fn is called from the calling
+// goroutine for every non-blank line, in input order. With ParseOptions.MaxBytes, longer
+// lines are reported as SizeLimitError without being read into memory.
+func ParseNDJSON(r io.Reader, workers int, fn NDJSONFunc, opts ...ParseOptions) error {
+	if nil == r || nil == fn {
+		return ParaError
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	opt := getParseOptions(opts)
+
+	jobs := make(chan *ndjsonJob, workers)
+	order := make(chan *ndjsonJob, 2*workers)
+	done := make(chan struct{})
+	defer close(done)
+
+	var rd_err error
+	go func() {
+		defer close(order)
+		defer close(jobs)
+		br := bufio.NewReader(r)
+		for line := 1; ; line++ {
+			data, err := readRecord(br, '\n', opt.MaxBytes)
+			if len(bytes.TrimSpace(data)) > 0 || err == SizeLimitError {
+				job := &ndjsonJob{line: line, data: data, ready: make(chan struct{})}
+				if err == SizeLimitError {
+					job.err = fmt.Errorf("line longer than %d bytes: %w", opt.MaxBytes, err)
+					err = nil
+				}
+				select {
+				case order <- job:
+				case <-done:
+					return
+				}
+				select {
+				case jobs <- job:
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					rd_err = err
+				}
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				if nil == job.err {
+					job.value, job.err = NewFromBytes(job.data, *opt)
+				}
+				job.data = nil
+				close(job.ready)
+			}
+		}()
+	}
+
+	for job := range order {
+		<-job.ready
+		var err error
+		if job.err != nil {
+			err = &LineError{Line: job.line, Err: job.err}
+		}
+		if err = fn(job.line, job.value, err); err != nil {
+			return err
+		}
+	}
+	return rd_err
+}
+
+// readRecord reads up to and including the next delim. If the record is longer than max
+// bytes, it is skipped and SizeLimitError is returned.
+func readRecord(br *bufio.Reader, delim byte, max int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := br.ReadSlice(delim)
+		if max > 0 && len(line)+len(bytes.TrimRight(chunk, "\r\n\x1e")) > max {
+			for err == bufio.ErrBufferFull {
+				_, err = br.ReadSlice(delim)
+			}
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			return nil, SizeLimitError
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package jsonconv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseNDJSON(t *testing.T) {
+	b := strings.Builder{}
+	for i := 0; i < 1000; i++ {
+		if i == 500 {
+			b.WriteString("{\"i\": oops}\n")
+			continue
+		}
+		if i%100 == 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "{\"i\": %d}\r\n", i)
+	}
+
+	next := 0
+	var line_err *LineError
+	err := ParseNDJSON(strings.NewReader(b.String()), 4, func(line int, v *JsonValue, err error) error {
+		if err != nil {
+			if false == errors.As(err, &line_err) || false == errors.Is(err, JsonFormatError) {
+				return err
+			}
+			next++
+			return nil
+		}
+		if i, _ := v.GetInt("i"); i != next {
+			return fmt.Errorf("line %d: got %d, expected %d", line, i, next)
+		}
+		next++
+		return nil
+	})
+	if err != nil {
+		t.Errorf("ParseNDJSON failed: %v", err)
+	}
+	if next != 1000 {
+		t.Errorf("%d values received", next)
+	}
+	if nil == line_err || line_err.Line != 506 {
+		t.Errorf("bad line error %v", line_err)
+	}
+
+	// stopping early
+	stop := errors.New("stop")
+	n := 0
+	err = ParseNDJSON(strings.NewReader(b.String()), 0, func(line int, v *JsonValue, err error) error {
+		n++
+		if n == 10 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 10 {
+		t.Errorf("unexpected result %v after %d lines", err, n)
+	}
+
+	// long lines
+	s := "[1]\n[" + strings.Repeat("1,", 100) + "1]\n[2]"
+	got := []string{}
+	ParseNDJSON(strings.NewReader(s), 2, func(line int, v *JsonValue, err error) error {
+		if err != nil {
+			if false == errors.Is(err, SizeLimitError) {
+				t.Errorf("unexpected error %v", err)
+			}
+			got = append(got, "error")
+			return nil
+		}
+		res, _ := v.MarshalToString()
+		got = append(got, res)
+		return nil
+	}, ParseOptions{MaxBytes: 100})
+	if strings.Join(got, " ") != "[1] error [2]" {
+		t.Errorf("unexpected results %v", got)
+	}
+}
@@ -0,0 +1,1115 @@
+package jsonconv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// SyntaxError describes where and why a JSON text could not be parsed.
+type SyntaxError struct {
+	Msg     string // description of the problem
+	Offset  int64  // byte offset of the error in the input
+	Line    int    // 1-based line number
+	Column  int    // 1-based column, counted in bytes
+	Snippet string // the offending line, followed by a line with a caret under the error
+	Err     error  // the specific error, if any, such as DuplicateKeyError
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at line %d, column %d (offset %d)", e.Msg, e.Line, e.Column, e.Offset)
+}
+
+// Unwrap returns Err, or JsonFormatError for plain syntax errors
+func (e *SyntaxError) Unwrap() error {
+	if e.Err != nil {
+		return e.Err
+	}
+	return JsonFormatError
+}
+
+// Position is where a value starts in the text it was parsed from
+type Position struct {
+	Offset int64 // byte offset in the input
+	Line   int   // 1-based line number, 0 if the value was not parsed
+	Column int   // 1-based column, counted in bytes
+}
+
+// IsValid tells whether the position is known
+func (pos Position) IsValid() bool {
+	return pos.Line > 0
+}
+
+// String returns "line:column", or "-" for values which were not parsed
+func (pos Position) String() string {
+	if false == pos.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
+// snippetWidth is the number of bytes shown on each side of the error in SyntaxError.Snippet
+const snippetWidth = 32
+
+// minRead is the minimum free space offered to io.Reader.Read when parsing from a stream
+const minRead = 4096
+
+type parser struct {
+	data      []byte
+	pos       int
+	line      int
+	lineStart int // may be negative once the beginning of the line has been discarded
+	// stream input, nil when parsing an in-memory document
+	rd    io.Reader
+	rdErr error
+	base  int64 // input offset of data[0]
+
+	opt   *ParseOptions
+	depth int
+	// input offset where MaxBytes starts counting, and whether fill hit that limit
+	docStart  int64
+	overLimit bool
+	// leave containers nested deeper than lazyBase unparsed, see NewLazyFromBytes
+	lazy     bool
+	lazyBase int
+	// allocate values from an arena, with text holding the input as a string
+	arena *Arena
+	text  string
+}
+
+func newParser(data []byte, opt *ParseOptions) *parser {
+	return &parser{
+		data: data,
+		line: 1,
+		opt:  opt,
+	}
+}
+
+func newStreamParser(r io.Reader, opt *ParseOptions) *parser {
+	if opt.PreserveFormat {
+		// stream buffers are reused, no layout can refer to them
+		o := *opt
+		o.PreserveFormat = false
+		opt = &o
+	}
+	return &parser{
+		data: make([]byte, 0, minRead),
+		line: 1,
+		rd:   r,
+		opt:  opt,
+	}
+}
+
+// ====================
+// error reporting
+
+func (p *parser) errorf(format string, args ...interface{}) *SyntaxError {
+	return p.errorAt(p.pos, format, args...)
+}
+
+// limitError reports that one of the limits in ParseOptions has been exceeded
+func (p *parser) limitError(pos int, err error, format string, args ...interface{}) *SyntaxError {
+	e := p.errorAt(pos, format, args...)
+	e.Err = err
+	return e
+}
+
+func (p *parser) errorAt(pos int, format string, args ...interface{}) *SyntaxError {
+	if p.overLimit {
+		// whatever went wrong, it happened because the input was cut at MaxBytes
+		p.overLimit = false
+		return p.sizeLimitError()
+	}
+
+	// pos may lie on an earlier line than the current one, e.g. at the beginning of a
+	// string which contains raw line breaks
+	line := p.line
+	lineStart := p.lineStart
+	if pos < lineStart {
+		line -= bytes.Count(p.data[pos:lineStart], []byte{'\n'})
+		lineStart = bytes.LastIndexByte(p.data[:pos], '\n') + 1
+	}
+
+	col := pos - lineStart
+	if lineStart < 0 {
+		// the beginning of the line has already been discarded from the stream buffer
+		lineStart = 0
+	}
+
+	lineEnd := bytes.IndexByte(p.data[lineStart:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(p.data)
+	} else {
+		lineEnd += lineStart
+	}
+
+	return &SyntaxError{
+		Msg:     fmt.Sprintf(format, args...),
+		Offset:  p.base + int64(pos),
+		Line:    line,
+		Column:  col + 1,
+		Snippet: makeSnippet(p.data[lineStart:lineEnd], pos-lineStart),
+	}
+}
+
+func makeSnippet(line []byte, col int) string {
+	from := 0
+	if col > snippetWidth {
+		from = col - snippetWidth
+	}
+	to := len(line)
+	if to-col > snippetWidth {
+		to = col + snippetWidth
+	}
+	line = bytes.TrimSuffix(line[from:to], []byte{'\r'})
+
+	caret := make([]byte, 0, col-from+1)
+	for _, c := range line[:col-from] {
+		if c == '\t' {
+			caret = append(caret, '\t')
+		} else {
+			caret = append(caret, ' ')
+		}
+	}
+	caret = append(caret, '^')
+
+	return string(line) + "\n" + string(caret)
+}
+
+func quoteChar(c byte) string {
+	if c < 0x20 || c >= 0x7F {
+		return fmt.Sprintf("0x%02X", c)
+	}
+	return fmt.Sprintf("'%c'", c)
+}
+
+// ====================
+// scanning
+
+func (p *parser) skipSpace() {
+	for false == p.eof() {
+		switch p.data[p.pos] {
+		case '\n':
+			p.line++
+			p.lineStart = p.pos + 1
+		case ' ', '\t', '\r':
+			// continue
+		case '/':
+			if false == (p.opt.Relaxed || p.opt.PreserveFormat) || false == p.skipComment() {
+				return
+			}
+			continue
+		default:
+			return
+		}
+		p.pos++
+	}
+}
+
+// skipComment moves past a // or /* */ comment if there is one at the current position.
+// An unterminated block comment extends to the end of input.
+func (p *parser) skipComment() bool {
+	p.ensure(2)
+	if p.pos+1 >= len(p.data) {
+		return false
+	}
+	switch p.data[p.pos+1] {
+	case '/':
+		for false == p.eof() && p.data[p.pos] != '\n' {
+			p.pos++
+		}
+		return true
+	case '*':
+		p.pos += 2
+		for false == p.eof() {
+			switch p.data[p.pos] {
+			case '\n':
+				p.line++
+				p.lineStart = p.pos + 1
+			case '*':
+				p.ensure(2)
+				if p.pos+1 < len(p.data) && p.data[p.pos+1] == '/' {
+					p.pos += 2
+					return true
+				}
+			}
+			p.pos++
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// eof tells whether the input is exhausted, reading more from the stream if necessary
+func (p *parser) eof() bool {
+	return p.pos >= len(p.data) && false == p.fill()
+}
+
+// ensure tries to make at least n unread bytes available
+func (p *parser) ensure(n int) {
+	for len(p.data)-p.pos < n && p.fill() {
+		// continue
+	}
+}
+
+// fill appends more stream input to data. Existing indexes into data stay valid.
+func (p *parser) fill() bool {
+	if p.rd == nil || p.rdErr != nil {
+		return false
+	}
+	if cap(p.data)-len(p.data) < minRead {
+		buf := make([]byte, len(p.data), 2*cap(p.data)+minRead)
+		copy(buf, p.data)
+		p.data = buf
+	}
+	free := p.data[len(p.data):cap(p.data)]
+	if p.opt.MaxBytes > 0 {
+		// read at most one byte beyond the limit, so that a document of exactly MaxBytes
+		// can still be terminated by end of input
+		room := p.docStart + int64(p.opt.MaxBytes) + 1 - p.base - int64(len(p.data))
+		if room <= 0 {
+			p.overLimit = true
+			return false
+		}
+		if room < int64(len(free)) {
+			free = free[:room]
+		}
+	}
+	for {
+		n, err := p.rd.Read(free)
+		p.data = p.data[:len(p.data)+n]
+		if err != nil {
+			p.rdErr = err
+		}
+		if n > 0 {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// compact discards consumed stream input. It must only be called between tokens. The
+// unread input is only moved once at least half of the buffer has been consumed, so that
+// each byte is moved a bounded number of times.
+func (p *parser) compact() {
+	if p.rd == nil || p.pos == 0 || 2*p.pos < len(p.data) {
+		return
+	}
+	n := copy(p.data, p.data[p.pos:])
+	p.data = p.data[:n]
+	p.base += int64(p.pos)
+	p.lineStart -= p.pos
+	p.pos = 0
+}
+
+// offset returns the input offset of the next unread byte
+func (p *parser) offset() int64 {
+	return p.base + int64(p.pos)
+}
+
+// readError returns the stream error which interrupted parsing, if any
+func (p *parser) readError() error {
+	if p.rdErr == nil || p.rdErr == io.EOF {
+		return nil
+	}
+	return p.rdErr
+}
+
+// ====================
+// parse functions
+
+// parseDocument parses the first value in the input. Anything after it is ignored unless
+// in strict mode.
+func (p *parser) parseDocument() (*JsonValue, error) {
+	if p.opt.MaxBytes > 0 && len(p.data) > p.opt.MaxBytes {
+		return nil, p.sizeLimitError()
+	}
+	if false == p.opt.Strict && false == p.opt.Relaxed && false == p.opt.PreserveFormat {
+		return p.parseValue()
+	}
+
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	end := p.pos
+	p.skipSpace()
+	if false == p.eof() && (p.opt.Strict || p.opt.Relaxed) {
+		return nil, p.errorf("unexpected character %s after top-level value", quoteChar(p.data[p.pos]))
+	}
+	if p.opt.PreserveFormat {
+		v.layout.lead = p.data[:v.layout.start]
+		v.layout.trail = p.data[end:]
+	}
+	return v, nil
+}
+
+func (p *parser) parseValue() (*JsonValue, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("unexpected end of input")
+	}
+	start := p.pos
+	at := p.position(start)
+	v, err := p.parseBareValue()
+	if err != nil {
+		return nil, err
+	}
+	v.pos = at
+	if p.opt.PreserveFormat && nil == v.layout {
+		v.layout = &sourceLayout{src: p.data, start: start, end: p.pos}
+	}
+	return v, nil
+}
+
+// position returns the Position of pos, which must lie on the current line
+func (p *parser) position(pos int) Position {
+	return Position{
+		Offset: p.base + int64(pos),
+		Line:   p.line,
+		Column: pos - p.lineStart + 1,
+	}
+}
+
+// parseBareValue parses the value starting at the current position
+func (p *parser) parseBareValue() (*JsonValue, error) {
+	switch p.data[p.pos] {
+	case '{':
+		if p.lazy && p.depth > p.lazyBase {
+			return p.parseLazy(Object)
+		}
+		return p.parseObject()
+	case '[':
+		if p.lazy && p.depth > p.lazyBase {
+			return p.parseLazy(Array)
+		}
+		return p.parseArray()
+	case '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return p.newString(s), nil
+	case 't', 'f', 'n':
+		return p.parseLiteral()
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return p.parseNumber()
+	}
+
+	if p.opt.Relaxed {
+		switch chr := p.data[p.pos]; chr {
+		case '\'':
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			return p.newString(s), nil
+		case '+', '.', 'I', 'N':
+			return p.parseNumber()
+		}
+	}
+	return nil, p.errorf("unexpected character %s", quoteChar(p.data[p.pos]))
+}
+
+// checkSize checks MaxBytes against the input consumed so far
+func (p *parser) checkSize() error {
+	if p.opt.MaxBytes > 0 && p.offset()-p.docStart > int64(p.opt.MaxBytes) {
+		return p.sizeLimitError()
+	}
+	return nil
+}
+
+func (p *parser) sizeLimitError() *SyntaxError {
+	limit := int(p.docStart + int64(p.opt.MaxBytes) - p.base)
+	if limit < 0 {
+		limit = 0
+	}
+	return p.limitError(limit, SizeLimitError, "document larger than %d bytes", p.opt.MaxBytes)
+}
+
+// enter is called when an object or array begins, leave when it ends
+func (p *parser) enter() error {
+	p.depth++
+	if p.opt.MaxDepth > 0 && p.depth > p.opt.MaxDepth {
+		return p.limitError(p.pos, DepthLimitError, "nesting deeper than %d levels", p.opt.MaxDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+// checkCount checks the number of members of an object or elements of an array
+func (p *parser) checkCount(pos int, n int, isObject bool) error {
+	if isObject && p.opt.MaxObjectKeys > 0 && n > p.opt.MaxObjectKeys {
+		return p.limitError(pos, ObjectKeysLimitError, "object with more than %d keys", p.opt.MaxObjectKeys)
+	}
+	if false == isObject && p.opt.MaxArrayLength > 0 && n > p.opt.MaxArrayLength {
+		return p.limitError(pos, ArrayLengthLimitError, "array with more than %d elements", p.opt.MaxArrayLength)
+	}
+	return nil
+}
+
+func (p *parser) parseObject() (*JsonValue, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	obj := p.newValue(Object)
+	key_mark := 0
+	if p.arena != nil {
+		obj.objChildren = p.arena.newMap()
+		key_mark = len(p.arena.keyStack)
+	} else {
+		obj.objChildren = make(map[string]*JsonValue)
+	}
+	open := p.pos
+	p.pos++ // '{'
+	var collected map[string]bool
+	var members []memberPos
+
+	p.skipSpace()
+	if false == p.eof() && p.data[p.pos] == '}' {
+		p.closeObject(obj, open, key_mark, members)
+		return obj, nil
+	}
+
+	for count := 1; ; count++ {
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf("unexpected end of input, expecting object key")
+		}
+		if count > 1 && p.opt.Relaxed && p.data[p.pos] == '}' {
+			// trailing comma
+			p.closeObject(obj, open, key_mark, members)
+			return obj, nil
+		}
+		if err := p.checkCount(p.pos, count, true); err != nil {
+			return nil, err
+		}
+		if false == p.isKeyStart(p.data[p.pos]) {
+			return nil, p.errorf("unexpected character %s, expecting object key", quoteChar(p.data[p.pos]))
+		}
+		key_pos := p.pos
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		key_end := p.pos
+
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf("unexpected end of input, expecting ':'")
+		}
+		if p.data[p.pos] != ':' {
+			return nil, p.errorf("unexpected character %s, expecting ':'", quoteChar(p.data[p.pos]))
+		}
+		p.pos++
+
+		keep := true
+		if p.opt.OnKey != nil && false == p.opt.PreserveFormat {
+			if key, keep, err = p.opt.OnKey(key); err != nil {
+				return nil, p.hookError(key_pos, err)
+			}
+		}
+		var child *JsonValue
+		if keep {
+			child, keep, err = p.parseMember()
+		} else {
+			err = p.skipValue()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			if err = p.addMember(obj, key, child, key_pos, &collected); err != nil {
+				return nil, err
+			}
+		}
+		if p.opt.PreserveFormat {
+			members = append(members, memberPos{start: key_pos, keyEnd: key_end, key: key, value: child, comma: -1})
+		}
+
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf("unexpected end of input, expecting ',' or '}'")
+		}
+		switch chr := p.data[p.pos]; chr {
+		case ',':
+			if p.opt.PreserveFormat {
+				members[len(members)-1].comma = p.pos
+			}
+			p.pos++
+		case '}':
+			p.closeObject(obj, open, key_mark, members)
+			return obj, nil
+		default:
+			return nil, p.errorf("unexpected character %s, expecting ',' or '}'", quoteChar(chr))
+		}
+	}
+}
+
+// parseMember parses the value of an object member or array element, passing leaves to
+// the OnValue hook. keep is false if the hook dropped the value.
+func (p *parser) parseMember() (v *JsonValue, keep bool, err error) {
+	p.skipSpace()
+	pos := p.pos
+	v, err = p.parseValue()
+	if err != nil {
+		return nil, false, err
+	}
+	if nil == p.opt.OnValue || p.opt.PreserveFormat || v.valueType == Object || v.valueType == Array {
+		return v, true, nil
+	}
+	replaced, err := p.opt.OnValue(v)
+	if err != nil {
+		return nil, false, p.hookError(pos, err)
+	}
+	if nil == replaced {
+		return nil, false, nil
+	}
+	if replaced != v {
+		replaced.pos = v.pos
+	}
+	return replaced, true, nil
+}
+
+// hookError reports an error returned by OnKey or OnValue
+func (p *parser) hookError(pos int, err error) *SyntaxError {
+	se := p.errorAt(pos, "%s", err.Error())
+	se.Err = err
+	return se
+}
+
+// addMember puts a parsed member into obj, resolving duplicate keys as configured.
+// collected records the keys whose values have already been gathered into an array.
+func (p *parser) addMember(obj *JsonValue, key string, child *JsonValue, keyPos int, collected *map[string]bool) error {
+	exist, dup := obj.objChildren[key]
+	if false == dup {
+		obj.objChildren[key] = child
+		if p.arena != nil {
+			p.arena.keyStack = append(p.arena.keyStack, key)
+		} else {
+			obj.objKeys = append(obj.objKeys, key)
+		}
+		return nil
+	}
+
+	switch p.opt.DuplicateKeys {
+	case DuplicateFirstWins:
+		// keep the existing one
+	case DuplicateError:
+		err := p.errorAt(keyPos, "duplicate key %q", key)
+		err.Err = DuplicateKeyError
+		return err
+	case DuplicateCollect:
+		if (*collected)[key] {
+			exist.arrChildren = append(exist.arrChildren, child)
+			break
+		}
+		arr := NewArray()
+		arr.arrChildren = append(arr.arrChildren, exist, child)
+		obj.objChildren[key] = arr
+		if nil == *collected {
+			*collected = map[string]bool{}
+		}
+		(*collected)[key] = true
+	default:
+		obj.objChildren[key] = child
+	}
+	return nil
+}
+
+func (p *parser) parseArray() (*JsonValue, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	arr := p.newValue(Array)
+	open := p.pos
+	p.pos++ // '['
+	var members []memberPos
+	mark := 0
+	if p.arena != nil {
+		mark = len(p.arena.stack)
+	}
+
+	p.skipSpace()
+	if false == p.eof() && p.data[p.pos] == ']' {
+		p.closeArray(arr, open, mark, members)
+		return arr, nil
+	}
+
+	for count := 1; ; count++ {
+		p.skipSpace()
+		if count > 1 && p.opt.Relaxed && false == p.eof() && p.data[p.pos] == ']' {
+			// trailing comma
+			p.closeArray(arr, open, mark, members)
+			return arr, nil
+		}
+		if err := p.checkCount(p.pos, count, false); err != nil {
+			return nil, err
+		}
+		child, keep, err := p.parseMember()
+		if err != nil {
+			return nil, err
+		}
+		if false == keep {
+			// dropped by OnValue
+		} else if p.arena != nil {
+			p.arena.stack = append(p.arena.stack, child)
+		} else {
+			arr.arrChildren = append(arr.arrChildren, child)
+		}
+		if p.opt.PreserveFormat {
+			members = append(members, memberPos{start: child.layout.start, value: child, comma: -1})
+		}
+
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf("unexpected end of input, expecting ',' or ']'")
+		}
+		switch chr := p.data[p.pos]; chr {
+		case ',':
+			if p.opt.PreserveFormat {
+				members[len(members)-1].comma = p.pos
+			}
+			p.pos++
+		case ']':
+			p.closeArray(arr, open, mark, members)
+			return arr, nil
+		default:
+			return nil, p.errorf("unexpected character %s, expecting ',' or ']'", quoteChar(chr))
+		}
+	}
+}
+
+// closeObject is closeContainer for objects, whose keys are stacked from mark on in the
+// arena if there is one
+func (p *parser) closeObject(obj *JsonValue, open int, mark int, members []memberPos) {
+	if p.arena != nil {
+		obj.objKeys = p.arena.keys(mark)
+	}
+	p.closeContainer(obj, open, members)
+}
+
+// closeArray is closeContainer for arrays, whose elements are stacked from mark on in
+// the arena if there is one
+func (p *parser) closeArray(arr *JsonValue, open int, mark int, members []memberPos) {
+	if p.arena != nil {
+		arr.arrChildren = p.arena.children(mark)
+	} else if nil == arr.arrChildren {
+		arr.arrChildren = []*JsonValue{}
+	}
+	p.closeContainer(arr, open, members)
+}
+
+// newValue allocates a value of type t
+func (p *parser) newValue(t ValueType) *JsonValue {
+	var v *JsonValue
+	if p.arena != nil {
+		v = p.arena.newValue()
+	} else {
+		v = new(JsonValue)
+	}
+	v.valueType = t
+	return v
+}
+
+func (p *parser) newString(s string) *JsonValue {
+	v := p.newValue(String)
+	v.stringValue = s
+	return v
+}
+
+// textOf returns data[start:end] as a string, sharing the memory of text if possible
+func (p *parser) textOf(start, end int) string {
+	if p.arena != nil {
+		return p.text[start:end]
+	}
+	return string(p.data[start:end])
+}
+
+// closeContainer moves past the closing bracket of v, recording its layout if needed
+func (p *parser) closeContainer(v *JsonValue, open int, members []memberPos) {
+	if p.opt.PreserveFormat {
+		v.layout = p.containerLayout(open, p.pos, members, v.valueType == Object)
+	}
+	p.pos++
+}
+
+func (p *parser) parseString() (string, error) {
+	start := p.pos
+	escaped, err := p.scanString()
+	if err != nil {
+		return "", err
+	}
+	raw := p.data[start+1 : p.pos-1]
+	var s string
+	if escaped {
+		s, err = stringFromEscapedBytes(raw, p.opt)
+	} else if p.arena != nil && (p.opt.InvalidUTF8 == InvalidUTF8PassThrough || utf8.Valid(raw)) {
+		s = p.textOf(start+1, p.pos-1)
+	} else {
+		s, err = stringFromRawBytes(raw, p.opt)
+	}
+	if err != nil {
+		return "", p.errorAt(start+1+err.(*escapeError).offset, "%s", err.Error())
+	}
+	if p.opt.MaxStringLength > 0 && len(s) > p.opt.MaxStringLength {
+		return "", p.limitError(start, StringLengthLimitError, "string longer than %d bytes", p.opt.MaxStringLength)
+	}
+	return s, nil
+}
+
+// scanString moves past a quoted string and tells whether it contains escape sequences.
+// Strings are quoted with '"', or also with '\'' in relaxed mode.
+func (p *parser) scanString() (escaped bool, err error) {
+	start := p.pos
+	quote := p.data[start]
+	p.pos++
+
+	// an escape sequence takes at most 6 bytes for one decoded byte
+	max_raw := 6*p.opt.MaxStringLength + 1
+
+	for false == p.eof() {
+		if p.opt.MaxStringLength > 0 && p.pos-start > max_raw {
+			return false, p.limitError(start, StringLengthLimitError, "string longer than %d bytes", p.opt.MaxStringLength)
+		}
+		switch chr := p.data[p.pos]; chr {
+		case quote:
+			p.pos++
+			return escaped, nil
+		case '\\':
+			// the next character can never terminate the string
+			escaped = true
+			p.pos++
+			if p.eof() {
+				continue
+			}
+			if p.data[p.pos] == '\n' {
+				p.line++
+				p.lineStart = p.pos + 1
+			}
+		case '\n':
+			p.line++
+			p.lineStart = p.pos + 1
+			if p.opt.Strict {
+				return false, p.errorf("unescaped line break in string")
+			}
+		default:
+			if chr < 0x20 && p.opt.Strict {
+				return false, p.errorf("unescaped control character %s in string", quoteChar(chr))
+			}
+		}
+		p.pos++
+	}
+	return false, p.errorAt(start, "unterminated string")
+}
+
+// isKeyStart tells whether chr may begin an object key
+func (p *parser) isKeyStart(chr byte) bool {
+	if chr == '"' {
+		return true
+	}
+	return p.opt.Relaxed && (chr == '\'' || isIdentifierByte(chr, false))
+}
+
+// parseKey reads an object key, which is a string or, in relaxed mode, an identifier
+func (p *parser) parseKey() (string, error) {
+	chr := p.data[p.pos]
+	if chr == '"' || chr == '\'' {
+		return p.parseString()
+	}
+	start := p.pos
+	if err := p.scanIdentifier(); err != nil {
+		return "", err
+	}
+	return p.textOf(start, p.pos), nil
+}
+
+func (p *parser) scanKey() error {
+	chr := p.data[p.pos]
+	if chr == '"' || chr == '\'' {
+		_, err := p.scanString()
+		return err
+	}
+	return p.scanIdentifier()
+}
+
+func (p *parser) scanIdentifier() error {
+	start := p.pos
+	for false == p.eof() && isIdentifierByte(p.data[p.pos], true) {
+		p.pos++
+		if p.opt.MaxStringLength > 0 && p.pos-start > p.opt.MaxStringLength {
+			return p.limitError(start, StringLengthLimitError, "key longer than %d bytes", p.opt.MaxStringLength)
+		}
+	}
+	return nil
+}
+
+// isIdentifierByte tells whether chr may be part of an unquoted key. Any non-ASCII byte
+// is accepted so that keys in other scripts work.
+func isIdentifierByte(chr byte, notFirst bool) bool {
+	switch {
+	case chr >= 'a' && chr <= 'z', chr >= 'A' && chr <= 'Z', chr == '_', chr == '$':
+		return true
+	case chr >= utf8.RuneSelf:
+		return true
+	case chr >= '0' && chr <= '9':
+		return notFirst
+	default:
+		return false
+	}
+}
+
+func (p *parser) parseNumber() (*JsonValue, error) {
+	start := p.pos
+	if err := p.scanNumber(); err != nil {
+		return nil, err
+	}
+	lit := p.textOf(start, p.pos)
+	obj := p.newValue(Number)
+	if err := setNumberValue(obj, lit); err != nil {
+		return nil, p.errorAt(start, "invalid number %q", lit)
+	}
+	return obj, nil
+}
+
+// scanNumber moves past the characters of a number literal. Only strict mode checks
+// them against the JSON number grammar here.
+func (p *parser) scanNumber() error {
+	start := p.pos
+	if p.opt.Relaxed {
+		if done, err := p.scanRelaxedNumber(); done || err != nil {
+			return err
+		}
+	}
+	for false == p.eof() && strings.IndexByte("0123456789+-.eE", p.data[p.pos]) >= 0 {
+		p.pos++
+		if p.opt.MaxNumberLength > 0 && p.pos-start > p.opt.MaxNumberLength {
+			return p.limitError(start, NumberLengthLimitError, "number longer than %d characters", p.opt.MaxNumberLength)
+		}
+	}
+	if p.opt.Strict {
+		if p.opt.Relaxed && p.data[start] == '+' {
+			start++
+		}
+		if bad := checkNumber(p.data[start:p.pos]); bad >= 0 {
+			return p.errorAt(start+bad, "invalid number %q", p.data[start:p.pos])
+		}
+	}
+	return nil
+}
+
+// scanRelaxedNumber moves past hexadecimal numbers, Infinity and NaN, each with an
+// optional sign. It returns false if the number is a decimal one.
+func (p *parser) scanRelaxedNumber() (bool, error) {
+	start := p.pos
+	p.ensure(10)
+	sign := 0
+	if p.data[start] == '+' || p.data[start] == '-' {
+		sign = 1
+	}
+	rest := p.data[start+sign:]
+	for _, lit := range []string{"Infinity", "NaN"} {
+		if bytes.HasPrefix(rest, []byte(lit)) {
+			p.pos = start + sign + len(lit)
+			return true, nil
+		}
+	}
+	if len(rest) < 2 || rest[0] != '0' || (rest[1] != 'x' && rest[1] != 'X') {
+		return false, nil
+	}
+
+	p.pos = start + sign + 2
+	for false == p.eof() && strings.IndexByte("0123456789abcdefABCDEF", p.data[p.pos]) >= 0 {
+		p.pos++
+		if p.opt.MaxNumberLength > 0 && p.pos-start > p.opt.MaxNumberLength {
+			return true, p.limitError(start, NumberLengthLimitError, "number longer than %d characters", p.opt.MaxNumberLength)
+		}
+	}
+	return true, nil
+}
+
+// checkNumber returns the index of the first byte in lit which violates the JSON number
+// grammar, or -1 if lit is a valid number.
+func checkNumber(lit []byte) int {
+	i := 0
+	digits := func() int {
+		n := 0
+		for i < len(lit) && lit[i] >= '0' && lit[i] <= '9' {
+			i++
+			n++
+		}
+		return n
+	}
+
+	if i < len(lit) && lit[i] == '-' {
+		i++
+	}
+	if i < len(lit) && lit[i] == '0' {
+		// no leading zeros
+		i++
+	} else if 0 == digits() {
+		return i
+	}
+	if i < len(lit) && lit[i] == '.' {
+		i++
+		if 0 == digits() {
+			return i
+		}
+	}
+	if i < len(lit) && (lit[i] == 'e' || lit[i] == 'E') {
+		i++
+		if i < len(lit) && (lit[i] == '+' || lit[i] == '-') {
+			i++
+		}
+		if 0 == digits() {
+			return i
+		}
+	}
+	if i < len(lit) {
+		return i
+	}
+	return -1
+}
+
+func (p *parser) parseLiteral() (*JsonValue, error) {
+	lit, err := p.scanLiteral()
+	if err != nil {
+		return nil, err
+	}
+	if lit == "null" {
+		return p.newValue(Null), nil
+	}
+	v := p.newValue(Boolean)
+	v.boolValue = lit == "true"
+	return v, nil
+}
+
+// scanLiteral moves past true, false or null and returns it
+func (p *parser) scanLiteral() (string, error) {
+	p.ensure(5)
+	rest := p.data[p.pos:]
+	for _, lit := range []string{"true", "false", "null"} {
+		if len(rest) < len(lit) || string(rest[:len(lit)]) != lit {
+			continue
+		}
+		if p.opt.Strict && len(rest) > len(lit) && strings.IndexByte(" \t\r\n,]}", rest[len(lit)]) < 0 {
+			break
+		}
+		p.pos += len(lit)
+		return lit, nil
+	}
+	return "", p.errorf("invalid literal")
+}
+
+// ====================
+// skip functions
+
+// skipValue moves past the next value without building it
+func (p *parser) skipValue() error {
+	p.skipSpace()
+	if p.eof() {
+		return p.errorf("unexpected end of input")
+	}
+
+	switch p.data[p.pos] {
+	case '{':
+		return p.skipContainer('}')
+	case '[':
+		return p.skipContainer(']')
+	case '"':
+		_, err := p.scanString()
+		return err
+	case 't', 'f', 'n':
+		_, err := p.scanLiteral()
+		return err
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return p.scanNumber()
+	}
+
+	if p.opt.Relaxed {
+		switch chr := p.data[p.pos]; chr {
+		case '\'':
+			_, err := p.scanString()
+			return err
+		case '+', '.', 'I', 'N':
+			return p.scanNumber()
+		}
+	}
+	return p.errorf("unexpected character %s", quoteChar(p.data[p.pos]))
+}
+
+func (p *parser) skipContainer(end byte) error {
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer p.leave()
+
+	p.pos++ // '{' or '['
+
+	p.skipSpace()
+	if false == p.eof() && p.data[p.pos] == end {
+		p.pos++
+		return nil
+	}
+
+	for count := 1; ; count++ {
+		p.skipSpace()
+		if count > 1 && p.opt.Relaxed && false == p.eof() && p.data[p.pos] == end {
+			// trailing comma
+			p.pos++
+			return nil
+		}
+		if err := p.checkCount(p.pos, count, end == '}'); err != nil {
+			return err
+		}
+		if end == '}' {
+			if p.eof() {
+				return p.errorf("unexpected end of input, expecting object key")
+			}
+			if false == p.isKeyStart(p.data[p.pos]) {
+				return p.errorf("unexpected character %s, expecting object key", quoteChar(p.data[p.pos]))
+			}
+			if err := p.scanKey(); err != nil {
+				return err
+			}
+			p.skipSpace()
+			if p.eof() {
+				return p.errorf("unexpected end of input, expecting ':'")
+			}
+			if p.data[p.pos] != ':' {
+				return p.errorf("unexpected character %s, expecting ':'", quoteChar(p.data[p.pos]))
+			}
+			p.pos++
+		}
+
+		if err := p.skipValue(); err != nil {
+			return err
+		}
+
+		p.skipSpace()
+		if p.eof() {
+			return p.errorf("unexpected end of input, expecting ',' or '%c'", end)
+		}
+		switch chr := p.data[p.pos]; chr {
+		case ',':
+			p.pos++
+		case end:
+			p.pos++
+			return nil
+		default:
+			return p.errorf("unexpected character %s, expecting ',' or '%c'", quoteChar(chr), end)
+		}
+	}
+}
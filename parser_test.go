@@ -0,0 +1,306 @@
+package jsonconv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestSyntaxError(t *testing.T) {
+	s := "{\n\t\"a\": 1,\n\t\"b\": [true, nul]\n}"
+	_, err := NewFromString(s)
+	if err == nil {
+		t.Error("error not detected")
+		return
+	}
+	if false == errors.Is(err, JsonFormatError) {
+		t.Errorf("%v is not a JsonFormatError", err)
+	}
+	var se *SyntaxError
+	if false == errors.As(err, &se) {
+		t.Errorf("unexpected error type %T", err)
+		return
+	}
+	if se.Line != 3 || se.Column != 14 || se.Offset != 24 {
+		t.Errorf("bad position %d:%d (offset %d)", se.Line, se.Column, se.Offset)
+	}
+	if se.Snippet != "\t\"b\": [true, nul]\n\t            ^" {
+		t.Errorf("bad snippet:\n%s", se.Snippet)
+	}
+
+	// errors inside escaped strings point at the escape sequence
+	_, err = NewFromString(`["abc\uzzzz"]`)
+	if false == errors.As(err, &se) {
+		t.Errorf("unexpected error %v", err)
+	} else if se.Offset != 5 {
+		t.Errorf("bad offset %d", se.Offset)
+	}
+}
+
+func TestNestedValues(t *testing.T) {
+	o, err := NewFromString(`{"arr": [1e3, -2, 18446744073709551615, "a\"b"], "obj": {"f": 1.5}}`)
+	if err != nil {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+	if f, _ := o.GetFloat("arr", 0); f != 1000 {
+		t.Errorf("bad exponent value %f", f)
+	}
+	if i, _ := o.GetInt("arr", 1); i != -2 {
+		t.Errorf("bad negative value %d", i)
+	}
+	if u, _ := o.GetUint64("arr", 2); u != 18446744073709551615 {
+		t.Errorf("bad unsigned value %d", u)
+	}
+	if s, _ := o.GetString("arr", 3); s != `a"b` {
+		t.Errorf("bad string value %s", s)
+	}
+	if f, _ := o.GetFloat("obj", "f"); f != 1.5 {
+		t.Errorf("bad float value %f", f)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	strict := ParseOptions{Strict: true}
+
+	for _, s := range []string{
+		`"abc" junk`,
+		`{"a": 1} {}`,
+		`[01]`,
+		`[-01.5]`,
+		`[1.]`,
+		`[.5]`,
+		`[1e]`,
+		`-`,
+		"[\"a\tb\"]",
+		"[\"a\nb\"]",
+		`["\g"]`,
+		`"\g\"`,
+		`["\ud83d"]`,
+		`["\ud83dx"]`,
+		`["\ud83dA"]`,
+		`["\ude00"]`,
+		`[truex]`,
+		`nulls`,
+	} {
+		if _, err := NewFromString(s, strict); err == nil {
+			t.Errorf("error not detected in strict mode: %s", s)
+		}
+	}
+
+	// accepted in default mode
+	for _, s := range []string{`"abc" junk`, `[01]`, "[\"a\tb\"]", `["\g"]`} {
+		if _, err := NewFromString(s); err != nil {
+			t.Errorf("unexpected error for %s: %v", s, err)
+		}
+	}
+
+	for _, s := range []string{
+		`"a\"bé😀\ud83d\ude00"`,
+		` [0, -0, 0.5, -1.5e+10, 2E-3, true, false, null] `,
+		`{"a": {"b": []}}`,
+	} {
+		if _, err := NewFromString(s, strict); err != nil {
+			t.Errorf("unexpected error for %s: %v", s, err)
+		}
+	}
+
+	for _, opt := range []ParseOptions{strict, {}} {
+		v, _ := NewFromString(`"a\"b\n"`, opt)
+		if v.String() != "a\"b\n" {
+			t.Errorf("top-level string not unescaped: %q", v.String())
+		}
+	}
+}
+
+func TestUnicodeEscapes(t *testing.T) {
+	v, err := NewFromString(`["\ud83d\ude00", "a\r\\b", "\ud83d!", "\u00e9"]`)
+	if err != nil {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+	for i, exp := range []string{"😀", "a\r\\b", "\uFFFD!", "é"} {
+		if s, _ := v.GetString(i); s != exp {
+			t.Errorf("%q != %q", s, exp)
+		}
+	}
+
+	s, _ := v.MarshalToString(Option{EnsureASCII: true})
+	if exp := `["\ud83d\ude00","a\r\\b","\ufffd!","\u00e9"]`; s != exp {
+		t.Errorf("%s != %s", s, exp)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	raw := "[\"a\xffb\", \"\\t\xfe\"]"
+
+	v, err := NewFromString(raw)
+	if err != nil || v.Len() != 2 {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+	if s, _ := v.GetString(0); s != "a\xffb" {
+		t.Errorf("bytes not passed through: %q", s)
+	}
+
+	v, _ = NewFromString(raw, ParseOptions{InvalidUTF8: InvalidUTF8Replace})
+	if s, _ := v.GetString(0); s != "a\uFFFDb" {
+		t.Errorf("bytes not replaced: %q", s)
+	}
+	if s, _ := v.GetString(1); s != "\t\uFFFD" {
+		t.Errorf("bytes not replaced: %q", s)
+	}
+
+	_, err = NewFromString(raw, ParseOptions{InvalidUTF8: InvalidUTF8Reject})
+	if se, ok := err.(*SyntaxError); false == ok || se.Offset != 3 {
+		t.Errorf("SyntaxError expected, got %v", err)
+	}
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	s := `{"a": 1, "b": 0, "a": 2, "a": [3]}`
+	expected := map[DuplicatePolicy]string{
+		DuplicateLastWins:  `{"a":[3],"b":0}`,
+		DuplicateFirstWins: `{"a":1,"b":0}`,
+		DuplicateCollect:   `{"a":[1,2,[3]],"b":0}`,
+	}
+	for policy, exp := range expected {
+		v, err := NewFromString(s, ParseOptions{DuplicateKeys: policy})
+		if err != nil {
+			t.Errorf("NewFromString failed: %v", err)
+			continue
+		}
+		if res, _ := v.MarshalToString(Option{SortMode: DictAsc}); res != exp {
+			t.Errorf("policy %d: %s != %s", policy, res, exp)
+		}
+	}
+
+	_, err := NewFromString(s, ParseOptions{DuplicateKeys: DuplicateError})
+	if false == errors.Is(err, DuplicateKeyError) {
+		t.Errorf("DuplicateKeyError expected, got %v", err)
+	} else if se := err.(*SyntaxError); se.Offset != 17 {
+		t.Errorf("bad offset %d", se.Offset)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	s := "{\n  \"server\": {\n    \"port\": 70000,\n    \"tags\": [\"a\", \"b\"]\n  }\n}"
+	v, err := NewFromString(s)
+	if err != nil {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+	if pos := v.Position(); pos.String() != "1:1" || pos.Offset != 0 {
+		t.Errorf("bad root position %v", pos)
+	}
+	port, _ := v.Get("server", "port")
+	if pos := port.Position(); pos.String() != "3:13" || pos.Offset != 28 {
+		t.Errorf("bad position %v (offset %d)", pos, pos.Offset)
+	}
+	tag, _ := v.Get("server", "tags", 1)
+	if pos := tag.Position(); pos.String() != "4:19" {
+		t.Errorf("bad position %v", pos)
+	}
+
+	if pos := NewInt(1).Position(); pos.IsValid() || pos.String() != "-" {
+		t.Errorf("unexpected position %v", pos)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	utf16le := func(s string, bom bool) []byte {
+		b := []byte{}
+		if bom {
+			b = append(b, 0xFF, 0xFE)
+		}
+		for _, u := range utf16.Encode([]rune(s)) {
+			b = append(b, byte(u), byte(u>>8))
+		}
+		return b
+	}
+	utf32be := func(s string) []byte {
+		b := []byte{}
+		for _, r := range s {
+			b = append(b, byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+		}
+		return b
+	}
+	s := `{"name": "Zoë 😀"}`
+
+	for i, b := range [][]byte{
+		append([]byte{0xEF, 0xBB, 0xBF}, s...),
+		utf16le(s, true),
+		utf16le(s, false),
+		utf32be(s),
+	} {
+		v, err := NewFromBytes(b, ParseOptions{Strict: true})
+		if err != nil {
+			t.Errorf("%d: NewFromBytes failed: %v", i, err)
+			continue
+		}
+		if name, _ := v.GetString("name"); name != "Zoë 😀" {
+			t.Errorf("%d: bad value %q", i, name)
+		}
+	}
+
+	// forced encoding
+	if _, err := NewFromBytes(utf16le("[1]", false), ParseOptions{Encoding: EncodingUTF8}); err == nil {
+		t.Errorf("UTF-16 accepted as UTF-8")
+	}
+	if v, err := NewFromBytes(utf16le("[1]", true), ParseOptions{Encoding: EncodingUTF16LE}); err != nil || v.Len() != 1 {
+		t.Errorf("unexpected result %v", err)
+	}
+
+	_, err := NewFromBytes(utf16le("[1]", true)[:7])
+	if se, ok := err.(*SyntaxError); false == ok || se.Offset != 6 {
+		t.Errorf("truncated input not detected: %v", err)
+	}
+}
+
+func TestParseHooks(t *testing.T) {
+	errForbidden := errors.New("forbidden field")
+	opt := ParseOptions{
+		OnKey: func(key string) (string, bool, error) {
+			switch key {
+			case "Password":
+				return "", false, nil
+			case "Admin":
+				return "", false, errForbidden
+			}
+			return strings.ToLower(key), true, nil
+		},
+		OnValue: func(v *JsonValue) (*JsonValue, error) {
+			if v.Type() == String {
+				s := strings.TrimSpace(v.String())
+				if s == "" {
+					return nil, nil
+				}
+				return NewString(s), nil
+			}
+			return v, nil
+		},
+	}
+
+	s := `{"Name": " Alice ", "Password": {"hash": "x"}, "Tags": [" a", "  ", "b "], "Age": 30}`
+	v, err := NewFromString(s, opt)
+	if err != nil {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+	if b, _ := v.Marshal(Option{SortMode: DictAsc}); string(b) != `{"age":30,"name":"Alice","tags":["a","b"]}` {
+		t.Errorf("unexpected result %s", b)
+	}
+	if name, _ := v.Get("name"); name.Position().String() != "1:10" {
+		t.Errorf("bad position %v", name.Position())
+	}
+
+	_, err = NewFromString(`{"name": "x", "Admin": true}`, opt)
+	if false == errors.Is(err, errForbidden) {
+		t.Errorf("unexpected error %v", err)
+	}
+	var se *SyntaxError
+	if false == errors.As(err, &se) || se.Offset != 14 {
+		t.Errorf("bad error %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package jsonconv
+
+import (
+	"strconv"
+	"strings"
+)
+
+type pathElemKind int
+
+const (
+	elemKey pathElemKind = iota
+	elemIndex
+	elemAnyKey
+	elemAnyIndex
+)
+
+type pathElem struct {
+	kind  pathElemKind
+	key   string
+	index int
+}
+
+// parsePath parses paths like `items[*].id`, `meta.cursor`, `*.name` or `["a.b"][0]`.
+// An empty path addresses the top-level value itself.
+func parsePath(s string) ([]pathElem, error) {
+	path := []pathElem{}
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, PathFormatError
+			}
+			inner := s[i+1 : i+end]
+			switch {
+			case inner == "*":
+				path = append(path, pathElem{kind: elemAnyIndex})
+			case strings.HasPrefix(inner, `"`):
+				// a quoted key may contain ']'
+				key, rest, err := unquotePathKey(s[i+1:])
+				if err != nil || false == strings.HasPrefix(rest, "]") {
+					return nil, PathFormatError
+				}
+				path = append(path, pathElem{kind: elemKey, key: key})
+				end = len(s) - len(rest) - i
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, PathFormatError
+				}
+				path = append(path, pathElem{kind: elemIndex, index: index})
+			}
+			i += end + 1
+
+		default:
+			// keys are separated by '.' unless they start the path
+			if s[i] == '.' {
+				if 0 == i {
+					return nil, PathFormatError
+				}
+				i++
+			} else if i > 0 {
+				return nil, PathFormatError
+			}
+			end := strings.IndexAny(s[i:], ".[")
+			if end < 0 {
+				end = len(s) - i
+			}
+			if 0 == end {
+				return nil, PathFormatError
+			}
+			key := s[i : i+end]
+			if key == "*" {
+				path = append(path, pathElem{kind: elemAnyKey})
+			} else {
+				path = append(path, pathElem{kind: elemKey, key: key})
+			}
+			i += end
+		}
+	}
+	return path, nil
+}
+
+// unquotePathKey reads a quoted key at the beginning of s and returns the rest of s
+func unquotePathKey(s string) (key string, rest string, err error) {
+	p := newParser([]byte(s), &dftParseOptions)
+	key, err = p.parseString()
+	if err != nil {
+		return "", "", err
+	}
+	return key, s[p.pos:], nil
+}
+
+// matchPath compares the concrete keys of a value with a path. It tells whether the
+// path addresses the value itself or one of its descendants.
+func matchPath(path []pathElem, keys []interface{}) (exact bool, prefix bool) {
+	if len(path) < len(keys) {
+		return false, false
+	}
+	for i, k := range keys {
+		switch k := k.(type) {
+		case string:
+			e := path[i]
+			if e.kind == elemAnyKey || (e.kind == elemKey && e.key == k) {
+				continue
+			}
+		case int:
+			e := path[i]
+			if e.kind == elemAnyIndex || (e.kind == elemIndex && e.index == k) {
+				continue
+			}
+		}
+		return false, false
+	}
+	return len(path) == len(keys), len(path) > len(keys)
+}
@@ -0,0 +1,388 @@
+package jsonconv
+
+import (
+	"fmt"
+)
+
+// what a PushParser is reading between calls to Write
+const (
+	collectNone = iota
+	collectKey
+	collectValue
+)
+
+type pushLevel struct {
+	kind  byte // '{' or '['
+	key   string
+	index int
+}
+
+// PushParser is an incremental parser which is fed with chunks of input of any size. It
+// passes every value at a configured depth to its handler as soon as the value is
+// complete, keeping only the value being read in memory. A stream may contain several
+// top-level values.
+//
+// ParseOptions.MaxBytes limits the size of each value handed to the handler. Relaxed
+// and PreserveFormat are not supported.
+type PushParser struct {
+	fn    ExtractFunc
+	opt   *ParseOptions
+	depth int
+
+	buf  []byte
+	pos  int
+	base int64 // input offset of buf[0]
+	// line number and input offset of the beginning of the line at pos
+	line      int
+	lineStart int64
+
+	// containers above depth
+	levels []pushLevel
+	state  tokenState
+
+	// key or value being read
+	collect   int
+	start     int
+	startLine int
+	startLS   int64
+	nest      int
+	inString  bool
+	escaped   bool
+	scalar    bool
+
+	err error
+}
+
+// NewPushParser creates a PushParser which calls fn with every value found depth levels
+// deep: depth 0 gives the top-level values, depth 1 the members or elements of the
+// top-level containers, and so on. Values above depth which are not containers are
+// skipped. The keys passed to fn are in the form described for ExtractFunc.
+func NewPushParser(depth int, fn ExtractFunc, opts ...ParseOptions) *PushParser {
+	opt := *getParseOptions(opts)
+	opt.Relaxed = false
+	opt.PreserveFormat = false
+	if depth < 0 {
+		depth = 0
+	}
+	return &PushParser{
+		fn:    fn,
+		opt:   &opt,
+		depth: depth,
+		line:  1,
+	}
+}
+
+// Write feeds the next chunk of input. It returns the first syntax error or handler
+// error, after which the parser stops accepting input.
+func (p *PushParser) Write(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	if nil == p.fn {
+		return 0, ParaError
+	}
+	p.buf = append(p.buf, b...)
+	if err := p.scan(false); err != nil {
+		p.err = err
+		return 0, err
+	}
+	p.compact()
+	return len(b), nil
+}
+
+// Close tells that the input has ended. It returns an error if the input ended in the
+// middle of a value.
+func (p *PushParser) Close() error {
+	if p.err != nil {
+		return p.err
+	}
+	if err := p.scan(true); err != nil {
+		p.err = err
+		return err
+	}
+	if p.collect != collectNone || len(p.levels) > 0 {
+		p.err = p.errorf(len(p.buf), "unexpected end of input")
+		return p.err
+	}
+	return nil
+}
+
+// compact discards the input which has been dealt with
+func (p *PushParser) compact() {
+	keep := p.pos
+	if p.collect != collectNone {
+		keep = p.start
+	}
+	if keep == 0 {
+		return
+	}
+	n := copy(p.buf, p.buf[keep:])
+	p.buf = p.buf[:n]
+	p.pos -= keep
+	if p.collect != collectNone {
+		p.start = 0
+	}
+	p.base += int64(keep)
+}
+
+func (p *PushParser) errorf(pos int, format string, args ...interface{}) *SyntaxError {
+	offset := p.base + int64(pos)
+	return &SyntaxError{
+		Msg:    fmt.Sprintf(format, args...),
+		Offset: offset,
+		Line:   p.line,
+		Column: int(offset-p.lineStart) + 1,
+	}
+}
+
+// scan goes through the buffered input
+func (p *PushParser) scan(atEOF bool) error {
+	for p.pos < len(p.buf) {
+		chr := p.buf[p.pos]
+		if p.collect != collectNone {
+			done, err := p.collectByte(chr)
+			if err != nil {
+				return err
+			}
+			if done {
+				if err = p.complete(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		p.advance(chr)
+		if isSpace(chr) {
+			continue
+		}
+		p.pos--
+		if err := p.structure(chr); err != nil {
+			return err
+		}
+	}
+
+	if atEOF && p.collect == collectValue && p.scalar {
+		return p.complete()
+	}
+	return nil
+}
+
+// advance moves past chr, which is at pos
+func (p *PushParser) advance(chr byte) {
+	p.pos++
+	if chr == '\n' {
+		p.line++
+		p.lineStart = p.base + int64(p.pos)
+	}
+}
+
+// structure handles chr at pos outside of keys and values
+func (p *PushParser) structure(chr byte) error {
+	switch p.state {
+	case stateValue, stateArrayStart:
+		if p.state == stateArrayStart && chr == ']' {
+			p.advance(chr)
+			p.pop()
+			return nil
+		}
+		return p.beginValue(chr)
+
+	case stateObjectStart, stateObjectKey:
+		if p.state == stateObjectStart && chr == '}' {
+			p.advance(chr)
+			p.pop()
+			return nil
+		}
+		if chr != '"' {
+			return p.errorf(p.pos, "unexpected character %s, expecting object key", quoteChar(chr))
+		}
+		p.begin(collectKey)
+		return nil
+
+	case stateObjectColon:
+		if chr != ':' {
+			return p.errorf(p.pos, "unexpected character %s, expecting ':'", quoteChar(chr))
+		}
+		p.advance(chr)
+		p.state = stateValue
+		return nil
+
+	default:
+		top := p.levels[len(p.levels)-1]
+		closer := byte(']')
+		if top.kind == '{' {
+			closer = '}'
+		}
+		switch chr {
+		case ',':
+			p.advance(chr)
+			if top.kind == '{' {
+				p.state = stateObjectKey
+			} else {
+				p.state = stateValue
+			}
+			return nil
+		case closer:
+			p.advance(chr)
+			p.pop()
+			return nil
+		}
+		return p.errorf(p.pos, "unexpected character %s, expecting ',' or %s", quoteChar(chr), quoteChar(closer))
+	}
+}
+
+func (p *PushParser) beginValue(chr byte) error {
+	if len(p.levels) > 0 && p.levels[len(p.levels)-1].kind == '[' {
+		p.levels[len(p.levels)-1].index++
+	}
+	if len(p.levels) < p.depth && (chr == '{' || chr == '[') {
+		if p.opt.MaxDepth > 0 && len(p.levels) >= p.opt.MaxDepth {
+			err := p.errorf(p.pos, "nesting deeper than %d levels", p.opt.MaxDepth)
+			err.Err = DepthLimitError
+			return err
+		}
+		p.advance(chr)
+		p.levels = append(p.levels, pushLevel{kind: chr, index: -1})
+		if chr == '{' {
+			p.state = stateObjectStart
+		} else {
+			p.state = stateArrayStart
+		}
+		return nil
+	}
+
+	switch chr {
+	case '{', '[', '"', 't', 'f', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		p.begin(collectValue)
+		return nil
+	}
+	return p.errorf(p.pos, "unexpected character %s", quoteChar(chr))
+}
+
+// begin starts collecting the key or value at pos
+func (p *PushParser) begin(what int) {
+	chr := p.buf[p.pos]
+	p.collect = what
+	p.start = p.pos
+	p.startLine = p.line
+	p.startLS = p.lineStart
+	p.nest = 0
+	p.escaped = false
+	p.inString = chr == '"'
+	p.scalar = false
+	switch chr {
+	case '{', '[':
+		p.nest = 1
+	case '"':
+	default:
+		p.scalar = true
+	}
+	p.advance(chr)
+}
+
+// collectByte handles chr at pos within a key or value and tells whether it has ended,
+// in which case it ends right before pos
+func (p *PushParser) collectByte(chr byte) (bool, error) {
+	if p.scalar {
+		switch chr {
+		case ' ', '\t', '\r', '\n', ',', ':', ']', '}', '[', '{', '"':
+			return true, nil
+		}
+	}
+	p.advance(chr)
+
+	if p.opt.MaxBytes > 0 && p.pos-p.start > p.opt.MaxBytes {
+		err := p.errorf(p.start, "value larger than %d bytes", p.opt.MaxBytes)
+		err.Err = SizeLimitError
+		return false, err
+	}
+
+	switch {
+	case p.scalar:
+		return false, nil
+	case p.inString:
+		if p.escaped {
+			p.escaped = false
+		} else if chr == '\\' {
+			p.escaped = true
+		} else if chr == '"' {
+			p.inString = false
+			return 0 == p.nest, nil
+		}
+	case chr == '"':
+		p.inString = true
+	case chr == '{' || chr == '[':
+		p.nest++
+	case chr == '}' || chr == ']':
+		p.nest--
+		return 0 == p.nest, nil
+	}
+	return false, nil
+}
+
+// complete handles the key or value collected from start to pos
+func (p *PushParser) complete() error {
+	what := p.collect
+	p.collect = collectNone
+
+	sub := newParser(p.buf[p.start:p.pos], p.opt)
+	sub.base = p.base + int64(p.start)
+	sub.line = p.startLine
+	sub.lineStart = int(p.startLS - sub.base)
+	sub.depth = len(p.levels)
+
+	if what == collectKey {
+		key, err := sub.parseString()
+		if err != nil {
+			return err
+		}
+		p.levels[len(p.levels)-1].key = key
+		p.state = stateObjectColon
+		return nil
+	}
+
+	if len(p.levels) == p.depth {
+		v, err := sub.parseValue()
+		if err != nil {
+			return err
+		}
+		if false == sub.eof() {
+			return sub.errorf("unexpected character %s", quoteChar(sub.data[sub.pos]))
+		}
+		keys := make([]interface{}, 0, len(p.levels))
+		for _, l := range p.levels {
+			if l.kind == '{' {
+				keys = append(keys, l.key)
+			} else {
+				keys = append(keys, l.index)
+			}
+		}
+		if err = p.fn(keys, v); err != nil {
+			return err
+		}
+	} else if err := sub.skipValue(); err != nil {
+		return err
+	} else if false == sub.eof() {
+		return sub.errorf("unexpected character %s", quoteChar(sub.data[sub.pos]))
+	}
+
+	p.valueDone()
+	return nil
+}
+
+func (p *PushParser) pop() {
+	p.levels = p.levels[:len(p.levels)-1]
+	p.valueDone()
+}
+
+// valueDone sets the state after a value
+func (p *PushParser) valueDone() {
+	if 0 == len(p.levels) {
+		p.state = stateValue
+	} else if p.levels[len(p.levels)-1].kind == '{' {
+		p.state = stateObjectNext
+	} else {
+		p.state = stateArrayNext
+	}
+}
@@ -0,0 +1,74 @@
+package jsonconv
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestPushParser(t *testing.T) {
+	s := "{\"a\": [1, \"x]\\\"\"]}\n 42 \"s\" [true, {\"b\": null}] 7"
+
+	got := []string{}
+	p := NewPushParser(0, func(keys []interface{}, v *JsonValue) error {
+		res, _ := v.MarshalToString(Option{ShowNull: true})
+		got = append(got, res)
+		return nil
+	})
+	// one byte at a time
+	for i := 0; i < len(s); i++ {
+		if _, err := p.Write([]byte{s[i]}); err != nil {
+			t.Errorf("Write failed at %d: %v", i, err)
+			return
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if exp := `[{"a":[1,"x]\""]} 42 "s" [true,{"b":null}] 7]`; fmt.Sprint(got) != exp {
+		t.Errorf("unexpected values %v", got)
+	}
+	if len(p.buf) > 1 {
+		t.Errorf("input kept in memory: %q", p.buf)
+	}
+
+	// elements of the top-level containers
+	got = got[:0]
+	positions := []string{}
+	p = NewPushParser(1, func(keys []interface{}, v *JsonValue) error {
+		res, _ := v.MarshalToString()
+		got = append(got, fmt.Sprintf("%v=%s", keys, res))
+		positions = append(positions, v.Position().String())
+		return nil
+	})
+	for _, chunk := range []string{`{"items": [1, 2`, `], "meta": {"n"`, ": 2}}\n[\"a\",\n \"b\"]"} {
+		if _, err := p.Write([]byte(chunk)); err != nil {
+			t.Errorf("Write failed: %v", err)
+			return
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if exp := `[[items]=[1,2] [meta]={"n":2} [0]="a" [1]="b"]`; fmt.Sprint(got) != exp {
+		t.Errorf("unexpected values %v", got)
+	}
+	if exp := `[1:11 1:27 2:2 3:2]`; fmt.Sprint(positions) != exp {
+		t.Errorf("unexpected positions %v", positions)
+	}
+
+	// errors
+	p = NewPushParser(1, func(keys []interface{}, v *JsonValue) error { return nil })
+	p.Write([]byte(`{"a": 1,`))
+	if err := p.Close(); false == errors.Is(err, JsonFormatError) {
+		t.Errorf("truncated input not detected: %v", err)
+	}
+	p = NewPushParser(1, func(keys []interface{}, v *JsonValue) error { return nil })
+	if _, err := p.Write([]byte(`[1, 2 3]`)); err == nil {
+		t.Errorf("syntax error not detected")
+	}
+	p = NewPushParser(0, func(keys []interface{}, v *JsonValue) error { return nil }, ParseOptions{MaxBytes: 8})
+	if _, err := p.Write([]byte(`[1] ["abcdefgh"]`)); false == errors.Is(err, SizeLimitError) {
+		t.Errorf("SizeLimitError expected, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package jsonconv
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const relaxedRaw = `// service config
+{
+	name: 'demo \'svc\'',   /* single quotes */
+	$port: 0x1F90,
+	ratio: +.5,
+	"limits": {
+		max: Infinity,
+		min: -Infinity,
+		undefined: NaN,
+	},
+	hosts: ["a", "b",],
+	中文: 1,
+}
+`
+
+func TestRelaxed(t *testing.T) {
+	if _, err := NewFromString(relaxedRaw); err == nil {
+		t.Error("relaxed syntax accepted in default mode")
+	}
+
+	v, err := NewFromString(relaxedRaw, ParseOptions{Relaxed: true})
+	if err != nil {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+	if s, _ := v.GetString("name"); s != "demo 'svc'" {
+		t.Errorf("bad name %q", s)
+	}
+	if i, _ := v.GetInt("$port"); i != 8080 {
+		t.Errorf("bad port %d", i)
+	}
+	if f, _ := v.GetFloat("ratio"); f != 0.5 {
+		t.Errorf("bad ratio %f", f)
+	}
+	if f, _ := v.GetFloat("limits", "max"); false == math.IsInf(f, 1) {
+		t.Errorf("bad max %f", f)
+	}
+	if f, _ := v.GetFloat("limits", "min"); false == math.IsInf(f, -1) {
+		t.Errorf("bad min %f", f)
+	}
+	if f, _ := v.GetFloat("limits", "undefined"); false == math.IsNaN(f) {
+		t.Errorf("bad NaN %f", f)
+	}
+	// non-finite numbers cannot be written as JSON
+	for _, s := range []string{`NaN`, `[Infinity]`, `{"a": -Infinity}`} {
+		n, _ := NewFromString(s, ParseOptions{Relaxed: true})
+		if res, err := n.MarshalToString(); err != JsonTypeError {
+			t.Errorf("JsonTypeError expected for %s, got %q (%v)", s, res, err)
+		}
+	}
+	v.Delete("limits")
+	if res, _ := v.MarshalToString(Option{SortMode: DictAsc}); false == strings.Contains(res, `"$port":8080,"hosts":["a","b"]`) {
+		t.Errorf("unexpected output %s", res)
+	}
+	if a, _ := v.Get("hosts"); a.Len() != 2 {
+		t.Errorf("bad hosts length %d", a.Len())
+	}
+	if i, _ := v.GetInt("中文"); i != 1 {
+		t.Errorf("bad unicode key %d", i)
+	}
+
+	for _, s := range []string{`[1,,]`, `{a: 1,,}`, `[,]`, `{,}`, `{1a: 1}`} {
+		if _, err := NewFromString(s, ParseOptions{Relaxed: true}); err == nil {
+			t.Errorf("error not detected: %s", s)
+		}
+	}
+}
+
+func TestRelaxedTokenizer(t *testing.T) {
+	tk := NewTokenizer(strings.NewReader(relaxedRaw), ParseOptions{Relaxed: true})
+	n := 0
+	for {
+		_, err := tk.Next()
+		if err != nil {
+			if n != 24 || tk.Depth() != 0 {
+				t.Errorf("stopped after %d tokens: %v", n, err)
+			}
+			break
+		}
+		n++
+	}
+
+	tk = NewTokenizer(strings.NewReader(relaxedRaw), ParseOptions{Relaxed: true})
+	if err := tk.Skip(); err != nil {
+		t.Errorf("Skip failed: %v", err)
+	}
+}
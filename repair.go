@@ -0,0 +1,480 @@
+package jsonconv
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Diagnostic describes one fix made by Repair
+type Diagnostic struct {
+	Position        // where the problem was found in the input
+	Msg      string // what was fixed
+}
+
+func (d Diagnostic) String() string {
+	return d.Position.String() + ": " + d.Msg
+}
+
+// states of the repairer
+const (
+	repairValue = iota // expecting a value
+	repairKey          // expecting an object key or '}'
+	repairColon        // expecting ':' after a key
+	repairNext         // expecting ',' or the end of the container
+)
+
+type repairer struct {
+	in    []byte
+	pos   int
+	out   bytes.Buffer
+	stack []byte // '{' and '[' of the open containers
+	state int
+	comma bool // a ',' has been read but not written yet
+	diags []Diagnostic
+
+	// last position computed for a diagnostic
+	line, lineStart, lineCounted int
+}
+
+// Repair turns truncated or malformed JSON into valid JSON on a best-effort basis. It
+// closes unterminated strings, arrays and objects, completes truncated numbers and
+// literals, drops dangling commas and unexpected characters, inserts missing commas,
+// colons and values, and quotes bare words. Every fix is reported as a Diagnostic.
+// Data after the first complete top-level value is dropped.
+func Repair(b []byte) ([]byte, []Diagnostic) {
+	r := &repairer{in: b, line: 1}
+	r.run()
+	return r.out.Bytes(), r.diags
+}
+
+// ParseLenient repairs b with Repair and parses the result. The error is only set if
+// nothing could be salvaged, e.g. when b holds no value at all.
+func ParseLenient(b []byte, opts ...ParseOptions) (*JsonValue, []Diagnostic, error) {
+	opt := *getParseOptions(opts)
+	b, err := toUTF8(b, &opt)
+	if err != nil {
+		return nil, nil, err
+	}
+	opt.Encoding = EncodingUTF8
+	opt.Strict = false
+	opt.Relaxed = false
+	opt.PreserveFormat = false
+
+	fixed, diags := Repair(b)
+	if 0 == len(fixed) {
+		return nil, diags, JsonFormatError
+	}
+	v, err := NewFromBytes(fixed, opt)
+	return v, diags, err
+}
+
+// ====================
+// repairer
+
+func (r *repairer) fix(pos int, format string, args ...interface{}) {
+	if pos < r.lineCounted {
+		// before the last fix, e.g. the start of a string holding other fixes
+		r.line, r.lineStart, r.lineCounted = 1, 0, 0
+	}
+	for ; r.lineCounted < pos && r.lineCounted < len(r.in); r.lineCounted++ {
+		if r.in[r.lineCounted] == '\n' {
+			r.line++
+			r.lineStart = r.lineCounted + 1
+		}
+	}
+	r.diags = append(r.diags, Diagnostic{
+		Position: Position{Offset: int64(pos), Line: r.line, Column: pos - r.lineStart + 1},
+		Msg:      fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *repairer) run() {
+	for {
+		for r.pos < len(r.in) && isSpace(r.in[r.pos]) {
+			r.pos++
+		}
+		if r.pos >= len(r.in) {
+			r.finish()
+			return
+		}
+		if 0 == len(r.stack) && r.state == repairNext {
+			r.fix(r.pos, "data after the top-level value dropped")
+			return
+		}
+
+		switch r.state {
+		case repairValue:
+			r.value()
+		case repairKey:
+			r.key()
+		case repairColon:
+			r.colon()
+		default:
+			r.next()
+		}
+	}
+}
+
+// writeComma writes the pending ',' before a value or key
+func (r *repairer) writeComma() {
+	if r.comma {
+		r.out.WriteByte(',')
+		r.comma = false
+	}
+}
+
+func (r *repairer) top() byte {
+	if 0 == len(r.stack) {
+		return 0
+	}
+	return r.stack[len(r.stack)-1]
+}
+
+// close writes the closer of the innermost container
+func (r *repairer) close() {
+	if r.top() == '{' {
+		r.out.WriteByte('}')
+	} else {
+		r.out.WriteByte(']')
+	}
+	r.stack = r.stack[:len(r.stack)-1]
+	r.state = repairNext
+}
+
+func (r *repairer) value() {
+	chr := r.in[r.pos]
+	switch {
+	case chr == '{' || chr == '[':
+		r.writeComma()
+		r.out.WriteByte(chr)
+		r.stack = append(r.stack, chr)
+		r.pos++
+		if chr == '{' {
+			r.state = repairKey
+		} else {
+			r.state = repairValue
+		}
+	case chr == ']' || chr == '}':
+		switch {
+		case 0 == len(r.stack):
+			r.fix(r.pos, "unexpected %s dropped", quoteChar(chr))
+			r.pos++
+		case r.top() == '{':
+			// after ':'
+			r.fix(r.pos, "missing value replaced with null")
+			r.out.WriteString("null")
+			r.state = repairNext
+		default:
+			if r.comma {
+				r.fix(r.pos, "dangling ',' dropped")
+				r.comma = false
+			}
+			if chr != ']' {
+				r.fix(r.pos, "'}' replaced with ']'")
+			}
+			r.pos++
+			r.close()
+		}
+	case chr == ',':
+		if r.top() == '{' {
+			r.fix(r.pos, "missing value replaced with null")
+			r.out.WriteString("null")
+			r.state = repairNext
+		} else {
+			r.fix(r.pos, "extra ',' dropped")
+			r.pos++
+		}
+	case chr == '"' || chr == '\'':
+		r.writeComma()
+		r.str()
+		r.state = repairNext
+	case chr == '-' || chr == '+' || chr == '.' || (chr >= '0' && chr <= '9'):
+		r.writeComma()
+		r.number()
+		r.state = repairNext
+	case isIdentifierByte(chr, false):
+		r.writeComma()
+		r.word()
+		r.state = repairNext
+	default:
+		r.fix(r.pos, "unexpected character %s dropped", quoteChar(chr))
+		r.pos++
+	}
+}
+
+func (r *repairer) key() {
+	chr := r.in[r.pos]
+	switch {
+	case chr == '"' || chr == '\'':
+		r.writeComma()
+		r.str()
+		r.state = repairColon
+	case chr == '}' || chr == ']':
+		if r.comma {
+			r.fix(r.pos, "dangling ',' dropped")
+			r.comma = false
+		}
+		if chr != '}' {
+			r.fix(r.pos, "']' replaced with '}'")
+		}
+		r.pos++
+		r.close()
+	case chr == ',':
+		r.fix(r.pos, "extra ',' dropped")
+		r.pos++
+	case isIdentifierByte(chr, false):
+		start := r.pos
+		for r.pos < len(r.in) && isIdentifierByte(r.in[r.pos], true) {
+			r.pos++
+		}
+		r.fix(start, "unquoted key quoted")
+		r.writeComma()
+		r.out.WriteString(`"` + escapeJsonString(string(r.in[start:r.pos]), &dftOption) + `"`)
+		r.state = repairColon
+	default:
+		r.fix(r.pos, "unexpected character %s dropped", quoteChar(chr))
+		r.pos++
+	}
+}
+
+func (r *repairer) colon() {
+	switch r.in[r.pos] {
+	case ':':
+		r.out.WriteByte(':')
+		r.pos++
+		r.state = repairValue
+	case ',', '}':
+		r.fix(r.pos, "missing value replaced with null")
+		r.out.WriteString(":null")
+		r.state = repairNext
+	default:
+		r.fix(r.pos, "missing ':' inserted")
+		r.out.WriteByte(':')
+		r.state = repairValue
+	}
+}
+
+func (r *repairer) next() {
+	chr := r.in[r.pos]
+	switch chr {
+	case ',':
+		r.comma = true
+		r.pos++
+		if r.top() == '{' {
+			r.state = repairKey
+		} else {
+			r.state = repairValue
+		}
+	case '}', ']':
+		expected := byte(']')
+		if r.top() == '{' {
+			expected = '}'
+		}
+		if chr != expected {
+			r.fix(r.pos, "%s replaced with %s", quoteChar(chr), quoteChar(expected))
+		}
+		r.pos++
+		r.close()
+	case ':':
+		r.fix(r.pos, "unexpected ':' dropped")
+		r.pos++
+	default:
+		r.fix(r.pos, "missing ',' inserted")
+		r.comma = true
+		if r.top() == '{' {
+			r.state = repairKey
+		} else {
+			r.state = repairValue
+		}
+	}
+}
+
+// finish completes the output at the end of the input
+func (r *repairer) finish() {
+	switch r.state {
+	case repairValue:
+		if 0 == len(r.stack) {
+			// no value at all
+			return
+		}
+		if r.top() == '{' {
+			r.fix(r.pos, "missing value replaced with null")
+			r.out.WriteString("null")
+		} else if r.comma {
+			r.fix(r.pos, "dangling ',' dropped")
+		}
+	case repairKey:
+		if r.comma {
+			r.fix(r.pos, "dangling ',' dropped")
+		}
+	case repairColon:
+		r.fix(r.pos, "missing value replaced with null")
+		r.out.WriteString(":null")
+	}
+
+	for len(r.stack) > 0 {
+		if r.top() == '{' {
+			r.fix(r.pos, "unterminated object closed")
+		} else {
+			r.fix(r.pos, "unterminated array closed")
+		}
+		r.close()
+	}
+}
+
+// str copies a string, which may be single-quoted, unterminated or contain raw control
+// characters
+func (r *repairer) str() {
+	start := r.pos
+	quote := r.in[start]
+	if quote == '\'' {
+		r.fix(start, "single-quoted string converted")
+	}
+	r.out.WriteByte('"')
+	r.pos++
+
+	for r.pos < len(r.in) {
+		chr := r.in[r.pos]
+		switch {
+		case chr == quote:
+			r.out.WriteByte('"')
+			r.pos++
+			return
+		case chr == '\\':
+			n := escapeLength(r.in[r.pos:])
+			if r.pos+n > len(r.in) {
+				r.fix(r.pos, "truncated escape sequence dropped")
+				r.pos = len(r.in)
+				break
+			}
+			if n == 6 && false == isHex(r.in[r.pos+2:r.pos+6]) {
+				r.fix(r.pos, "invalid escape sequence dropped")
+				r.pos += 2
+				break
+			}
+			esc := r.in[r.pos+1]
+			switch {
+			case esc == '\'':
+				if quote != '\'' {
+					r.fix(r.pos, "invalid escape sequence %q replaced with \"'\"", r.in[r.pos:r.pos+2])
+				}
+				r.out.WriteByte('\'')
+			case n == 2 && strings.IndexByte(`"\\/bfnrt`, esc) < 0:
+				// the escaped backslash is followed by the character as it is
+				r.fix(r.pos, "invalid escape sequence %q kept as text", r.in[r.pos:r.pos+2])
+				r.out.WriteString(`\\`)
+				r.pos++
+				continue
+			default:
+				r.out.Write(r.in[r.pos : r.pos+n])
+			}
+			r.pos += n
+		case chr == '"':
+			// in a single-quoted string
+			r.out.WriteString(`\"`)
+			r.pos++
+		case chr < 0x20:
+			r.fix(r.pos, "control character %s escaped", quoteChar(chr))
+			r.out.WriteString(escapeJsonString(string(chr), &dftOption))
+			r.pos++
+		default:
+			r.out.WriteByte(chr)
+			r.pos++
+		}
+	}
+	r.fix(start, "unterminated string closed")
+	r.out.WriteByte('"')
+}
+
+// escapeLength returns the length of the escape sequence at the beginning of b
+func escapeLength(b []byte) int {
+	if len(b) >= 2 && b[1] == 'u' {
+		return 6
+	}
+	return 2
+}
+
+func (r *repairer) number() {
+	start := r.pos
+	for r.pos < len(r.in) && strings.IndexByte("+-.eE0123456789", r.in[r.pos]) >= 0 {
+		r.pos++
+	}
+	lit := string(r.in[start:r.pos])
+	if checkNumber([]byte(lit)) < 0 {
+		r.out.WriteString(lit)
+		return
+	}
+
+	fixed := strings.TrimPrefix(lit, "+")
+	if strings.HasPrefix(fixed, ".") || strings.HasPrefix(fixed, "-.") {
+		fixed = strings.Replace(fixed, ".", "0.", 1)
+	}
+	// leading zeros
+	sign := ""
+	if strings.HasPrefix(fixed, "-") {
+		sign, fixed = "-", fixed[1:]
+	}
+	fixed = strings.TrimLeft(fixed, "0")
+	if "" == fixed || false == isDigit(fixed[0]) {
+		fixed = "0" + fixed
+	}
+	fixed = sign + fixed
+	if idx := checkNumber([]byte(fixed)); idx >= 0 {
+		fixed = strings.TrimRight(fixed[:idx], ".eE+-")
+	}
+	if "" == fixed || "-" == fixed || checkNumber([]byte(fixed)) >= 0 {
+		fixed = "0"
+	}
+	r.fix(start, "number %q replaced with %s", lit, fixed)
+	r.out.WriteString(fixed)
+}
+
+// word handles literals, truncated literals and bare words
+func (r *repairer) word() {
+	start := r.pos
+	for r.pos < len(r.in) && isIdentifierByte(r.in[r.pos], true) {
+		r.pos++
+	}
+	w := string(r.in[start:r.pos])
+
+	switch w {
+	case "true", "false", "null":
+		r.out.WriteString(w)
+		return
+	case "True", "TRUE":
+		w = "true"
+	case "False", "FALSE":
+		w = "false"
+	case "None", "NULL", "undefined", "nil":
+		w = "null"
+	default:
+		if r.pos == len(r.in) {
+			for _, lit := range []string{"true", "false", "null"} {
+				if strings.HasPrefix(lit, w) {
+					r.fix(start, "truncated literal %q completed", w)
+					r.out.WriteString(lit)
+					return
+				}
+			}
+		}
+		r.fix(start, "bare word %q quoted", w)
+		r.out.WriteString(`"` + escapeJsonString(w, &dftOption) + `"`)
+		return
+	}
+	r.fix(start, "%q replaced with %s", string(r.in[start:r.pos]), w)
+	r.out.WriteString(w)
+}
+
+func isHex(b []byte) bool {
+	for _, chr := range b {
+		if false == (chr >= '0' && chr <= '9' || chr >= 'a' && chr <= 'f' || chr >= 'A' && chr <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func isSpace(chr byte) bool {
+	return chr == ' ' || chr == '\t' || chr == '\n' || chr == '\r'
+}
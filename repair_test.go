@@ -0,0 +1,65 @@
+package jsonconv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	for _, c := range []struct {
+		in, out string
+		fixes   int
+	}{
+		{`{"a": 1, "b": [1, 2, "thr`, `{"a":1,"b":[1,2,"thr"]}`, 3},
+		{`{"a": 1,}`, `{"a":1}`, 1},
+		{`[1, 2,,]`, `[1,2]`, 2},
+		{`[1 2 3]`, `[1,2,3]`, 2},
+		{`{"a": 1 "b": tr`, `{"a":1,"b":true}`, 3},
+		{`{a: 'it\'s "x"', "b":}`, `{"a":"it's \"x\"","b":null}`, 3},
+		{`{"a": [1, 2}`, `{"a":[1,2]}`, 2},
+		{`{"n": -1.}`, `{"n":-1}`, 1},
+		{`{"s": "a` + "\n" + `b", "t": True}`, `{"s":"a\nb","t":true}`, 2},
+		{`{"k"`, `{"k":null}`, 2},
+		{`{"u": "\u12`, `{"u":""}`, 3},
+		{`[01, -0123, 00.5, 0, 1e05]`, `[1,-123,0.5,0,1e05]`, 3},
+		{`["a\g", "b\'", 'c\'']`, `["a\\g","b'","c'"]`, 3},
+		{"[\"a\\\n\"]", `["a\\\n"]`, 2},
+		{`[1] [2]`, `[1]`, 1},
+		{`{"a": {"b": [`, `{"a":{"b":[]}}`, 3},
+		{`[{"x": 1}, {"x": 2}`, `[{"x":1},{"x":2}]`, 1},
+	} {
+		res, diags := Repair([]byte(c.in))
+		if string(res) != c.out {
+			t.Errorf("%s: %s != %s", c.in, res, c.out)
+		}
+		if len(diags) != c.fixes {
+			t.Errorf("%s: %d fixes: %v", c.in, len(diags), diags)
+		}
+	}
+
+	// positions of fixes made before earlier ones
+	_, diags := Repair([]byte("[1,\n\"a\n\nb"))
+	positions := []string{}
+	for _, d := range diags {
+		positions = append(positions, d.Position.String())
+	}
+	if strings.Join(positions, " ") != "2:3 3:1 2:1 4:2" {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+
+	v, diags, err := ParseLenient([]byte("{\n  \"items\": [\n    {\"id\": 1},\n    {\"id\": 2, \"name\": \"tw"))
+	if err != nil {
+		t.Errorf("ParseLenient failed: %v", err)
+		return
+	}
+	if name, _ := v.GetString("items", 1, "name"); name != "tw" {
+		t.Errorf("bad value %q", name)
+	}
+	if len(diags) != 4 || diags[0].String() != "4:23: unterminated string closed" {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+
+	if _, _, err = ParseLenient([]byte(" , ")); err != JsonFormatError {
+		t.Errorf("JsonFormatError expected, got %v", err)
+	}
+}
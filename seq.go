@@ -0,0 +1,140 @@
+package jsonconv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// recordSeparator starts every JSON text of an RFC 7464 sequence (application/json-seq)
+const recordSeparator = 0x1E
+
+// RecordError is returned for a JSON text of a sequence which could not be parsed, e.g.
+// because it was truncated. The SeqReader goes on with the next text.
+type RecordError struct {
+	Record int // 1-based index of the text in the sequence
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Record, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// SeqReader reads an RFC 7464 JSON text sequence, where every text is preceded by an RS
+// (0x1E) character. ParseOptions.MaxBytes limits the size of each text.
+type SeqReader struct {
+	br     *bufio.Reader
+	opt    *ParseOptions
+	record int
+	begun  bool
+	err    error
+}
+
+func NewSeqReader(r io.Reader, opts ...ParseOptions) *SeqReader {
+	return &SeqReader{
+		br:  bufio.NewReader(r),
+		opt: getParseOptions(opts),
+	}
+}
+
+// Read returns the next JSON text of the sequence, or io.EOF at its end. A text which
+// cannot be parsed is reported as a *RecordError, after which Read may be called again.
+// As required by the RFC, a top-level number, true, false or null which is not followed
+// by whitespace is considered truncated.
+func (s *SeqReader) Read() (*JsonValue, error) {
+	for {
+		if s.err != nil {
+			return nil, s.err
+		}
+
+		rec, err := readRecord(s.br, recordSeparator, s.opt.MaxBytes)
+		switch err {
+		case nil:
+		case io.EOF:
+			s.err = io.EOF
+		case SizeLimitError:
+			s.begun = true
+			s.record++
+			err = fmt.Errorf("text longer than %d bytes: %w", s.opt.MaxBytes, err)
+			return nil, &RecordError{Record: s.record, Err: err}
+		default:
+			s.err = err
+			return nil, err
+		}
+		rec = bytes.TrimSuffix(rec, []byte{recordSeparator})
+
+		if 0 == len(bytes.TrimSpace(rec)) {
+			// consecutive separators, or nothing before the first one
+			s.begun = true
+			continue
+		}
+		s.record++
+		if false == s.begun {
+			s.begun = true
+			return nil, &RecordError{Record: s.record, Err: fmt.Errorf("%w: text without record separator", JsonFormatError)}
+		}
+
+		v, err := s.parse(rec)
+		if err != nil {
+			return nil, &RecordError{Record: s.record, Err: err}
+		}
+		return v, nil
+	}
+}
+
+func (s *SeqReader) parse(rec []byte) (*JsonValue, error) {
+	p := newParser(rec, s.opt)
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	end := p.pos
+	p.skipSpace()
+	if false == p.eof() {
+		return nil, p.errorf("unexpected character %s after JSON text", quoteChar(p.data[p.pos]))
+	}
+	switch v.valueType {
+	case Number, Boolean, Null:
+		if end == len(rec) {
+			return nil, p.errorAt(end, "JSON text may have been truncated")
+		}
+	}
+	return v, nil
+}
+
+// SeqWriter writes an RFC 7464 JSON text sequence
+type SeqWriter struct {
+	w   io.Writer
+	opt Option
+}
+
+func NewSeqWriter(w io.Writer, opts ...Option) *SeqWriter {
+	s := &SeqWriter{
+		w:   w,
+		opt: dftOption,
+	}
+	if len(opts) > 0 {
+		s.opt = opts[0]
+	}
+	return s
+}
+
+// Write writes the marshaled v between an RS and a line feed
+func (s *SeqWriter) Write(v *JsonValue) error {
+	if nil == v {
+		return ParaError
+	}
+	buff := bytes.Buffer{}
+	buff.WriteByte(recordSeparator)
+	if err := v.marshalToBuffer(&buff, s.opt); err != nil {
+		return err
+	}
+	buff.WriteByte('\n')
+	_, err := s.w.Write(buff.Bytes())
+	return err
+}
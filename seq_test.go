@@ -0,0 +1,57 @@
+package jsonconv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSeq(t *testing.T) {
+	buff := bytes.Buffer{}
+	w := NewSeqWriter(&buff)
+	for _, s := range []string{`{"a":1}`, `[true]`, `42`} {
+		v, _ := NewFromString(s)
+		if err := w.Write(v); err != nil {
+			t.Errorf("Write failed: %v", err)
+		}
+	}
+	if exp := "\x1e{\"a\":1}\n\x1e[true]\n\x1e42\n"; buff.String() != exp {
+		t.Errorf("unexpected output %q", buff.String())
+	}
+
+	// a truncated object, a truncated number and garbage, between valid texts
+	in := buff.String() + "\x1e{\"b\": [1, 2\x1e\x1e\"x\"\n\x1e12\x1e{} junk\n\x1e null \n"
+	r := NewSeqReader(strings.NewReader(in))
+	got := []string{}
+	for {
+		v, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		var rec_err *RecordError
+		if errors.As(err, &rec_err) {
+			got = append(got, "error")
+			continue
+		}
+		if err != nil {
+			t.Errorf("Read failed: %v", err)
+			return
+		}
+		s, _ := v.MarshalToString(Option{ShowNull: true})
+		got = append(got, s)
+	}
+	if exp := `{"a":1} [true] 42 error "x" error error null`; strings.Join(got, " ") != exp {
+		t.Errorf("unexpected results %s", strings.Join(got, " "))
+	}
+
+	// data before the first separator
+	r = NewSeqReader(strings.NewReader("{}\n\x1e[]\n"))
+	if _, err := r.Read(); false == errors.Is(err, JsonFormatError) {
+		t.Errorf("JsonFormatError expected, got %v", err)
+	}
+	if v, err := r.Read(); err != nil || false == v.IsArray() {
+		t.Errorf("unexpected result %v, %v", v, err)
+	}
+}
@@ -0,0 +1,340 @@
+package jsonconv
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+type TokenKind int
+
+const (
+	TokenInvalid TokenKind = iota
+	TokenBeginObject
+	TokenEndObject
+	TokenBeginArray
+	TokenEndArray
+	TokenKey
+	TokenString
+	TokenNumber
+	TokenBool
+	TokenNull
+)
+
+// Token is a single lexical element of a JSON stream
+type Token struct {
+	Kind TokenKind
+	// decoded text of keys and strings, the literal of numbers, "true", "false" or "null"
+	Value string
+	// input offset where the token begins
+	Offset int64
+}
+
+type tokenState int
+
+const (
+	stateValue       tokenState = iota // expecting a value
+	stateArrayStart                    // after '[': value or ']'
+	stateArrayNext                     // after an element: ',' or ']'
+	stateObjectStart                   // after '{': key or '}'
+	stateObjectKey                     // after ',' in an object: key
+	stateObjectColon                   // after a key: ':'
+	stateObjectNext                    // after a member: ',' or '}'
+)
+
+// Tokenizer reads a JSON stream token by token without building JsonValue trees. Any
+// value at the current position can be built with ReadValue or skipped with Skip instead.
+type Tokenizer struct {
+	p      *parser
+	stack  []byte // '{' or '[' of every open container
+	counts []int  // number of members or elements seen in every open container
+	state  tokenState
+	err    error
+	// whether the last separator was a ',', which may be trailing in relaxed mode
+	afterComma bool
+}
+
+func NewTokenizer(r io.Reader, opts ...ParseOptions) *Tokenizer {
+	return &Tokenizer{
+		p: newStreamParser(r, getParseOptions(opts)),
+	}
+}
+
+// Next returns the next token. It returns io.EOF when the stream ends between two
+// top-level values.
+func (t *Tokenizer) Next() (Token, error) {
+	chr, err := t.prepare()
+	if err != nil {
+		return Token{}, err
+	}
+
+	if err = t.countElement(chr); err != nil {
+		return Token{}, t.fail(err)
+	}
+
+	p := t.p
+	tok := Token{Offset: p.offset()}
+	switch {
+	case chr == '}':
+		p.pos++
+		tok.Kind = TokenEndObject
+		t.pop()
+	case chr == ']':
+		p.pos++
+		tok.Kind = TokenEndArray
+		t.pop()
+	case t.state == stateObjectStart || t.state == stateObjectKey:
+		tok.Kind = TokenKey
+		tok.Value, err = p.parseKey()
+		t.state = stateObjectColon
+		t.afterComma = false
+	case chr == '{':
+		tok.Kind = TokenBeginObject
+		err = t.push('{')
+		t.state = stateObjectStart
+	case chr == '[':
+		tok.Kind = TokenBeginArray
+		err = t.push('[')
+		t.state = stateArrayStart
+	case chr == '"' || (chr == '\'' && p.opt.Relaxed):
+		tok.Kind = TokenString
+		tok.Value, err = p.parseString()
+		t.valueDone()
+	case chr == 't' || chr == 'f':
+		tok.Kind = TokenBool
+		tok.Value, err = p.scanLiteral()
+		t.valueDone()
+	case chr == 'n':
+		tok.Kind = TokenNull
+		tok.Value, err = p.scanLiteral()
+		t.valueDone()
+	case chr == '-' || (chr >= '0' && chr <= '9') || (p.opt.Relaxed && strings.IndexByte("+.IN", chr) >= 0):
+		tok.Kind = TokenNumber
+		start := p.pos
+		if err = p.scanNumber(); err == nil {
+			tok.Value = string(p.data[start:p.pos])
+			if _, err = strconv.ParseFloat(tok.Value, 64); err != nil && p.opt.Relaxed {
+				_, err = parseNumberValue(tok.Value)
+			}
+			if err != nil {
+				err = p.errorAt(start, "invalid number %q", tok.Value)
+			}
+		}
+		t.valueDone()
+	default:
+		err = p.errorf("unexpected character %s", quoteChar(chr))
+	}
+
+	if err != nil {
+		return Token{}, t.fail(err)
+	}
+	return tok, nil
+}
+
+// More reports whether the current array or object has another element. At top level
+// it reports whether the stream has another value.
+func (t *Tokenizer) More() bool {
+	chr, err := t.prepare()
+	return err == nil && chr != ']' && chr != '}'
+}
+
+// ReadValue builds the value at the current position, including all its children
+func (t *Tokenizer) ReadValue() (*JsonValue, error) {
+	if err := t.prepareValue(); err != nil {
+		return nil, err
+	}
+	t.p.depth = len(t.stack)
+	v, err := t.p.parseValue()
+	if err != nil {
+		return nil, t.fail(err)
+	}
+	t.valueDone()
+	return v, nil
+}
+
+// Skip moves past the value at the current position without building it
+func (t *Tokenizer) Skip() error {
+	if err := t.prepareValue(); err != nil {
+		return err
+	}
+	t.p.depth = len(t.stack)
+	if err := t.p.skipValue(); err != nil {
+		return t.fail(err)
+	}
+	t.valueDone()
+	return nil
+}
+
+// Depth returns the number of currently open objects and arrays
+func (t *Tokenizer) Depth() int {
+	return len(t.stack)
+}
+
+// InputOffset returns the byte offset of the current tokenizer position in the stream
+func (t *Tokenizer) InputOffset() int64 {
+	return t.p.offset()
+}
+
+// ====================
+// internal functions
+
+// prepare consumes whitespace and separators up to the next token and returns its first
+// character
+func (t *Tokenizer) prepare() (byte, error) {
+	if t.err != nil {
+		return 0, t.err
+	}
+
+	p := t.p
+	p.compact()
+	if err := p.checkSize(); err != nil {
+		return 0, t.fail(err)
+	}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			if err := p.readError(); err != nil {
+				return 0, t.fail(err)
+			}
+			if t.state == stateValue && 0 == len(t.stack) {
+				return 0, t.fail(io.EOF)
+			}
+			return 0, t.fail(p.errorf("unexpected end of input"))
+		}
+
+		chr := p.data[p.pos]
+		relaxed_end := p.opt.Relaxed && t.afterComma && chr == t.closer()
+		switch t.state {
+		case stateValue:
+			if relaxed_end {
+				return chr, nil
+			}
+			if chr == ']' || chr == '}' || chr == ',' || chr == ':' {
+				return 0, t.fail(p.errorf("unexpected character %s, expecting a value", quoteChar(chr)))
+			}
+			return chr, nil
+		case stateArrayStart:
+			if chr == '}' || chr == ',' || chr == ':' {
+				return 0, t.fail(p.errorf("unexpected character %s, expecting a value or ']'", quoteChar(chr)))
+			}
+			return chr, nil
+		case stateObjectStart:
+			if false == p.isKeyStart(chr) && chr != '}' {
+				return 0, t.fail(p.errorf("unexpected character %s, expecting object key", quoteChar(chr)))
+			}
+			return chr, nil
+		case stateObjectKey:
+			if relaxed_end {
+				return chr, nil
+			}
+			if false == p.isKeyStart(chr) {
+				return 0, t.fail(p.errorf("unexpected character %s, expecting object key", quoteChar(chr)))
+			}
+			return chr, nil
+		case stateObjectColon:
+			if chr != ':' {
+				return 0, t.fail(p.errorf("unexpected character %s, expecting ':'", quoteChar(chr)))
+			}
+			p.pos++
+			t.state = stateValue
+		case stateArrayNext:
+			if chr == ']' {
+				return chr, nil
+			}
+			if chr != ',' {
+				return 0, t.fail(p.errorf("unexpected character %s, expecting ',' or ']'", quoteChar(chr)))
+			}
+			p.pos++
+			t.state = stateValue
+			t.afterComma = true
+		case stateObjectNext:
+			if chr == '}' {
+				return chr, nil
+			}
+			if chr != ',' {
+				return 0, t.fail(p.errorf("unexpected character %s, expecting ',' or '}'", quoteChar(chr)))
+			}
+			p.pos++
+			t.state = stateObjectKey
+			t.afterComma = true
+		}
+	}
+}
+
+// prepareValue is prepare for callers which need a value at the current position
+func (t *Tokenizer) prepareValue() error {
+	chr, err := t.prepare()
+	if err != nil {
+		return err
+	}
+	if chr == ']' || chr == '}' || t.state == stateObjectStart || t.state == stateObjectKey {
+		return NotAValueError
+	}
+	if err = t.countElement(chr); err != nil {
+		return t.fail(err)
+	}
+	return nil
+}
+
+// countElement checks the size limits when an array element or object key begins
+func (t *Tokenizer) countElement(chr byte) error {
+	if 0 == len(t.stack) || chr == ']' || chr == '}' {
+		return nil
+	}
+	top := len(t.stack) - 1
+	if t.stack[top] == '{' && t.state != stateObjectStart && t.state != stateObjectKey {
+		// a member value, its key has been counted
+		return nil
+	}
+	t.counts[top]++
+	return t.p.checkCount(t.p.pos, t.counts[top], t.stack[top] == '{')
+}
+
+// closer returns the character which closes the innermost container
+func (t *Tokenizer) closer() byte {
+	switch {
+	case 0 == len(t.stack):
+		return 0
+	case t.stack[len(t.stack)-1] == '[':
+		return ']'
+	default:
+		return '}'
+	}
+}
+
+func (t *Tokenizer) push(chr byte) error {
+	t.afterComma = false
+	t.p.pos++
+	t.stack = append(t.stack, chr)
+	t.counts = append(t.counts, 0)
+	if max := t.p.opt.MaxDepth; max > 0 && len(t.stack) > max {
+		return t.p.limitError(t.p.pos-1, DepthLimitError, "nesting deeper than %d levels", max)
+	}
+	return nil
+}
+
+func (t *Tokenizer) pop() {
+	t.stack = t.stack[:len(t.stack)-1]
+	t.counts = t.counts[:len(t.counts)-1]
+	t.valueDone()
+}
+
+func (t *Tokenizer) valueDone() {
+	t.afterComma = false
+	switch {
+	case 0 == len(t.stack):
+		t.state = stateValue
+	case t.stack[len(t.stack)-1] == '[':
+		t.state = stateArrayNext
+	default:
+		t.state = stateObjectNext
+	}
+}
+
+func (t *Tokenizer) fail(err error) error {
+	if rd_err := t.p.readError(); rd_err != nil {
+		err = rd_err
+	}
+	t.err = err
+	return err
+}
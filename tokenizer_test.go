@@ -0,0 +1,130 @@
+package jsonconv
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestTokenizer(t *testing.T) {
+	s := `{"a": [1, "x\ty", true, null], "b": {}} -1.5`
+	tk := NewTokenizer(iotest.OneByteReader(strings.NewReader(s)))
+
+	expected := []Token{
+		{TokenBeginObject, "", 0},
+		{TokenKey, "a", 1},
+		{TokenBeginArray, "", 6},
+		{TokenNumber, "1", 7},
+		{TokenString, "x\ty", 10},
+		{TokenBool, "true", 18},
+		{TokenNull, "null", 24},
+		{TokenEndArray, "", 28},
+		{TokenKey, "b", 31},
+		{TokenBeginObject, "", 36},
+		{TokenEndObject, "", 37},
+		{TokenEndObject, "", 38},
+		{TokenNumber, "-1.5", 40},
+	}
+	for i, exp := range expected {
+		tok, err := tk.Next()
+		if err != nil {
+			t.Errorf("Next failed: %v", err)
+			return
+		}
+		if tok != exp {
+			t.Errorf("token %d: %+v != %+v", i, tok, exp)
+		}
+	}
+	if _, err := tk.Next(); err != io.EOF {
+		t.Errorf("io.EOF expected, got %v", err)
+	}
+}
+
+func TestTokenizerReadValue(t *testing.T) {
+	s := `{"items": [{"id": 1, "skip": [1, [2]]}, {"id": 2}, {"id": 3}], "n": 0}`
+	tk := NewTokenizer(strings.NewReader(s))
+
+	tk.Next() // {
+	if _, err := tk.ReadValue(); err != NotAValueError {
+		t.Errorf("NotAValueError expected, got %v", err)
+	}
+	tk.Next() // "items"
+	tk.Next() // [
+
+	ids := []int{}
+	for i := 0; tk.More(); i++ {
+		if i == 1 {
+			if err := tk.Skip(); err != nil {
+				t.Errorf("Skip failed: %v", err)
+			}
+			continue
+		}
+		v, err := tk.ReadValue()
+		if err != nil {
+			t.Errorf("ReadValue failed: %v", err)
+			return
+		}
+		id, _ := v.GetInt("id")
+		ids = append(ids, id)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Errorf("unexpected ids %v", ids)
+	}
+
+	if tok, _ := tk.Next(); tok.Kind != TokenEndArray {
+		t.Errorf("unexpected token %+v", tok)
+	}
+	if tok, _ := tk.Next(); tok.Kind != TokenKey || tok.Value != "n" || tk.Depth() != 1 {
+		t.Errorf("unexpected token %+v", tok)
+	}
+	if v, err := tk.ReadValue(); err != nil || v.Int() != 0 {
+		t.Errorf("ReadValue failed: %v", err)
+	}
+	if tok, _ := tk.Next(); tok.Kind != TokenEndObject || tk.Depth() != 0 {
+		t.Errorf("unexpected token %+v", tok)
+	}
+}
+
+func TestTokenizerError(t *testing.T) {
+	tk := NewTokenizer(strings.NewReader(`[1 2]`))
+	tk.Next()
+	tk.Next()
+	_, err := tk.Next()
+	if se, ok := err.(*SyntaxError); false == ok || se.Offset != 3 {
+		t.Errorf("SyntaxError expected, got %v", err)
+	}
+	if _, again := tk.Next(); again != err {
+		t.Errorf("error should be sticky, got %v", again)
+	}
+}
+
+func TestTokenizerBuffer(t *testing.T) {
+	n := 100000
+	s := "[" + strings.Repeat(`{"a":1,"b":"x"},`, n) + "1]"
+	tk := NewTokenizer(strings.NewReader(s))
+	count := 0
+	for {
+		tok, err := tk.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Errorf("Next failed: %v", err)
+			return
+		}
+		if tok.Kind == TokenKey {
+			count++
+		}
+		if c := cap(tk.p.data); c > 4*minRead {
+			t.Errorf("buffer grew to %d bytes", c)
+			return
+		}
+	}
+	if count != 2*n {
+		t.Errorf("%d keys read", count)
+	}
+	if off := tk.p.offset(); off != int64(len(s)) {
+		t.Errorf("bad offset %d", off)
+	}
+}
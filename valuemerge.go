@@ -7,8 +7,21 @@ func (to *JsonValue) copyFrom(from *JsonValue) {
 	to.intValue = from.intValue
 	to.floatValue = from.floatValue
 	to.boolValue = from.boolValue
-	to.objChildren = from.objChildren
+	to.literal = from.literal
+	// the members of objects are copied, since the key order would not stay in step
+	// with a shared map
+	to.objChildren, to.objKeys = nil, nil
+	if from.objChildren != nil {
+		to.objChildren = make(map[string]*JsonValue, len(from.objChildren))
+		for k, v := range from.objChildren {
+			to.objChildren[k] = v
+		}
+		to.objKeys = append([]string{}, from.objKeys...)
+	}
 	to.arrChildren = from.arrChildren
+	to.pos = from.pos
+	to.layout = from.layout
+	to.lazy = from.lazy
 }
 
 func (to *JsonValue) MergeFrom(from *JsonValue, optList ...Option) error {
@@ -54,6 +67,12 @@ func (to *JsonValue) MergeFrom(from *JsonValue, optList ...Option) error {
 			to.copyFrom(from)
 		} else {
 			// append
+			if err := to.expand(); err != nil {
+				return err
+			}
+			if err := from.expand(); err != nil {
+				return err
+			}
 			to.arrChildren = append(to.arrChildren, from.arrChildren...)
 		}
 	default:
@@ -0,0 +1,167 @@
+package jsonconv
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxExponent limits the exponent of literals converted to exact values, whose size
+// grows with it
+const maxExponent = 100000
+
+// NewNumberLiteral creates a Number from a JSON number literal such as "1e5" or
+// "12345678901234567.89". The literal is kept as it is and written back by Marshal, so
+// that no precision is lost.
+func NewNumberLiteral(s string) (*JsonValue, error) {
+	if checkNumber([]byte(s)) >= 0 {
+		return nil, JsonFormatError
+	}
+	return parseNumberValue(s)
+}
+
+// BigInt returns the exact value of an integer Number, whatever its size
+func (obj *JsonValue) BigInt() (*big.Int, error) {
+	r, err := obj.bigRat()
+	if err != nil {
+		return nil, err
+	}
+	if false == r.IsInt() {
+		return nil, NotAnIntegerError
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
+
+// BigFloat returns the value of a Number with enough precision for all digits of its
+// literal
+func (obj *JsonValue) BigFloat() (*big.Float, error) {
+	if obj.valueType != Number {
+		return nil, NotANumberError
+	}
+	if obj.literal != "" {
+		if _, err := literalExponent(obj.literal); err != nil {
+			return nil, err
+		}
+		prec := uint(4 * len(obj.literal))
+		if prec < 64 {
+			prec = 64
+		}
+		f, _, err := big.ParseFloat(obj.literal, 10, prec, big.ToNearestEven)
+		return f, err
+	}
+	if obj.mustFloat || float64(obj.intValue) != obj.floatValue && false == obj.mustUnsigned {
+		if math.IsNaN(obj.floatValue) {
+			return nil, NotANumberError
+		}
+		return big.NewFloat(obj.floatValue), nil
+	}
+	if obj.mustUnsigned {
+		return new(big.Float).SetUint64(obj.uintValue), nil
+	}
+	return new(big.Float).SetInt64(obj.intValue), nil
+}
+
+// DecimalString returns the exact value of a Number in plain decimal notation, without
+// any exponent, such as "123456789012345.678" or "0.00001"
+func (obj *JsonValue) DecimalString() (string, error) {
+	if obj.valueType != Number {
+		return "", NotANumberError
+	}
+	if obj.literal != "" {
+		return decimalFromLiteral(obj.literal)
+	}
+	if obj.mustFloat || float64(obj.intValue) != obj.floatValue && false == obj.mustUnsigned {
+		if math.IsNaN(obj.floatValue) || math.IsInf(obj.floatValue, 0) {
+			return "", NotANumberError
+		}
+		return strconv.FormatFloat(obj.floatValue, 'f', -1, 64), nil
+	}
+	if obj.mustUnsigned {
+		return strconv.FormatUint(obj.uintValue, 10), nil
+	}
+	return strconv.FormatInt(obj.intValue, 10), nil
+}
+
+func (obj *JsonValue) bigRat() (*big.Rat, error) {
+	if obj.valueType != Number {
+		return nil, NotANumberError
+	}
+	if obj.literal != "" {
+		if _, err := literalExponent(obj.literal); err != nil {
+			return nil, err
+		}
+		r, ok := new(big.Rat).SetString(obj.literal)
+		if false == ok {
+			return nil, NumberRangeError
+		}
+		return r, nil
+	}
+	if obj.mustFloat || float64(obj.intValue) != obj.floatValue && false == obj.mustUnsigned {
+		r := new(big.Rat).SetFloat64(obj.floatValue)
+		if nil == r {
+			return nil, NotANumberError
+		}
+		return r, nil
+	}
+	if obj.mustUnsigned {
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(obj.uintValue)), nil
+	}
+	return new(big.Rat).SetInt64(obj.intValue), nil
+}
+
+// decimalFromLiteral moves the decimal point of a valid JSON number literal by its exponent
+func decimalFromLiteral(lit string) (string, error) {
+	sign := ""
+	if strings.HasPrefix(lit, "-") {
+		sign = "-"
+		lit = lit[1:]
+	}
+	exp, err := literalExponent(lit)
+	if err != nil {
+		return "", err
+	}
+	if idx := strings.IndexAny(lit, "eE"); idx >= 0 {
+		lit = lit[:idx]
+	}
+	int_part, frac_part := lit, ""
+	if idx := strings.IndexByte(lit, '.'); idx >= 0 {
+		int_part, frac_part = lit[:idx], lit[idx+1:]
+	}
+	if 0 == exp {
+		return sign + lit, nil
+	}
+
+	digits := int_part + frac_part
+	point := len(int_part) + exp
+	switch {
+	case point <= 0:
+		int_part, frac_part = "0", strings.Repeat("0", -point)+digits
+	case point >= len(digits):
+		int_part, frac_part = digits+strings.Repeat("0", point-len(digits)), ""
+	default:
+		int_part, frac_part = digits[:point], digits[point:]
+	}
+	int_part = strings.TrimLeft(int_part, "0")
+	if "" == int_part {
+		int_part = "0"
+	}
+	if "" == frac_part {
+		return sign + int_part, nil
+	}
+	return sign + int_part + "." + frac_part, nil
+}
+
+// literalExponent returns the exponent of a valid JSON number literal, or
+// NumberRangeError if it is beyond maxExponent
+func literalExponent(lit string) (int, error) {
+	idx := strings.IndexAny(lit, "eE")
+	if idx < 0 {
+		return 0, nil
+	}
+	exp, err := strconv.Atoi(strings.TrimPrefix(lit[idx+1:], "+"))
+	if err != nil || exp > maxExponent || exp < -maxExponent {
+		return 0, NumberRangeError
+	}
+	return exp, nil
+}
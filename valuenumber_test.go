@@ -0,0 +1,87 @@
+package jsonconv
+
+import (
+	"testing"
+)
+
+func TestNumberLiteral(t *testing.T) {
+	s := `{"exp": 1e5, "money": 123456789012345.678, "big": 123456789012345678901234567890, "neg": -98765432109876543210, "small": 1.5e-7, "u": 18446744073709551615}`
+	v, err := NewFromString(s)
+	if err != nil {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+
+	if i, _ := v.GetInt("exp"); i != 100000 {
+		t.Errorf("bad exponent value %d", i)
+	}
+	if u, _ := v.GetUint64("u"); u != 18446744073709551615 {
+		t.Errorf("bad unsigned value %d", u)
+	}
+	for key, exp := range map[string]string{
+		"exp":   "100000",
+		"money": "123456789012345.678",
+		"big":   "123456789012345678901234567890",
+		"neg":   "-98765432109876543210",
+		"small": "0.00000015",
+	} {
+		child, _ := v.Get(key)
+		if s, err := child.DecimalString(); err != nil || s != exp {
+			t.Errorf("%s: %s != %s (%v)", key, s, exp, err)
+		}
+	}
+
+	big, _ := v.Get("big")
+	if i, err := big.BigInt(); err != nil || i.String() != "123456789012345678901234567890" {
+		t.Errorf("bad BigInt %v (%v)", i, err)
+	}
+	money, _ := v.Get("money")
+	if _, err := money.BigInt(); err != NotAnIntegerError {
+		t.Errorf("NotAnIntegerError expected, got %v", err)
+	}
+	if f, err := money.BigFloat(); err != nil || f.Text('f', 3) != "123456789012345.678" {
+		t.Errorf("bad BigFloat %v (%v)", f, err)
+	}
+
+	// literals are written back as they are
+	if res, _ := v.MarshalToString(Option{SortMode: DictAsc}); res != `{"big":123456789012345678901234567890,"exp":1e5,"money":123456789012345.678,"neg":-98765432109876543210,"small":1.5e-7,"u":18446744073709551615}` {
+		t.Errorf("unexpected output %s", res)
+	}
+
+	n, err := NewNumberLiteral("-0.10")
+	if err != nil {
+		t.Errorf("NewNumberLiteral failed: %v", err)
+	} else if f := n.Float(); f != -0.1 {
+		t.Errorf("bad value %f", f)
+	}
+	if _, err = NewNumberLiteral("01"); err != JsonFormatError {
+		t.Errorf("JsonFormatError expected, got %v", err)
+	}
+	if s, _ := NewInt(-42).DecimalString(); s != "-42" {
+		t.Errorf("bad decimal string %s", s)
+	}
+}
+
+func TestNumberExponentRange(t *testing.T) {
+	v, err := NewFromString(`{"huge": 1e99999999999, "tiny": -2.5E-99999999999999999999, "ok": 1e3}`)
+	if err != nil {
+		t.Errorf("NewFromString failed: %v", err)
+		return
+	}
+	for _, key := range []string{"huge", "tiny"} {
+		n, _ := v.Get(key)
+		if _, err := n.DecimalString(); err != NumberRangeError {
+			t.Errorf("%s: NumberRangeError expected, got %v", key, err)
+		}
+		if _, err := n.BigInt(); err != NumberRangeError {
+			t.Errorf("%s: NumberRangeError expected, got %v", key, err)
+		}
+		if _, err := n.BigFloat(); err != NumberRangeError {
+			t.Errorf("%s: NumberRangeError expected, got %v", key, err)
+		}
+	}
+	ok, _ := v.Get("ok")
+	if s, err := ok.DecimalString(); err != nil || s != "1000" {
+		t.Errorf("bad decimal string %s (%v)", s, err)
+	}
+}
@@ -9,43 +9,199 @@ type valuePair struct {
 	V *JsonValue
 }
 
-type ascending []*valuePair
-func (this ascending) Len() int {
-	return len(this)
+// KeyOrder tells whether object key a is written before key b
+type KeyOrder func(a, b string) bool
+
+type byKey struct {
+	pairs []*valuePair
+	less  KeyOrder
+}
+func (this byKey) Len() int {
+	return len(this.pairs)
 }
-func (this ascending) Less(i, j int) bool {
-	return strings.Compare(this[i].K, this[j].K) < 0
+func (this byKey) Less(i, j int) bool {
+	return this.less(this.pairs[i].K, this.pairs[j].K)
 }
-func (this ascending) Swap(i, j int) {
-	this[i], this[j] = this[j], this[i]
+func (this byKey) Swap(i, j int) {
+	this.pairs[i], this.pairs[j] = this.pairs[j], this.pairs[i]
 	return
 }
 
-type descending []*valuePair
-func (this descending) Len() int {
-	return len(this)
+// DictOrder sorts keys by their bytes, like SortMode DictAsc
+func DictOrder(a, b string) bool {
+	return a < b
 }
-func (this descending) Less(i, j int) bool {
-	return strings.Compare(this[i].K, this[j].K) > 0
+
+// NaturalOrder sorts numbers within keys by their value, so that "item2" comes before
+// "item10"
+func NaturalOrder(a, b string) bool {
+	if c := naturalCompare(a, b); c != 0 {
+		return c < 0
+	}
+	return a < b
 }
-func (this descending) Swap(i, j int) {
-	this[i], this[j] = this[j], this[i]
-	return
+
+// CaseInsensitiveOrder sorts keys regardless of letter case
+func CaseInsensitiveOrder(a, b string) bool {
+	if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c < 0
+	}
+	return a < b
 }
 
-func sortObjects(obj *JsonValue, mode Sort) []*valuePair {
-	ret := make([]*valuePair, 0, obj.Length())
-	obj.ObjectForeach(func (k string, v *JsonValue) error {
-		ret = append(ret, &valuePair{K: k, V: v})
-		return nil
-	})
+// PriorityOrder writes the keys listed in first in that order, before all other keys,
+// which are sorted by rest, or by DictOrder if rest is nil
+func PriorityOrder(first []string, rest KeyOrder) KeyOrder {
+	rank := make(map[string]int, len(first))
+	for i, key := range first {
+		if _, exist := rank[key]; false == exist {
+			rank[key] = i
+		}
+	}
+	if nil == rest {
+		rest = DictOrder
+	}
+	return func(a, b string) bool {
+		ra, a_listed := rank[a]
+		rb, b_listed := rank[b]
+		switch {
+		case a_listed && b_listed:
+			return ra < rb
+		case a_listed || b_listed:
+			return a_listed
+		default:
+			return rest(a, b)
+		}
+	}
+}
+
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			num_a, rest_a := digitPrefix(a)
+			num_b, rest_b := digitPrefix(b)
+			num_a = strings.TrimLeft(num_a, "0")
+			num_b = strings.TrimLeft(num_b, "0")
+			if len(num_a) != len(num_b) {
+				return len(num_a) - len(num_b)
+			}
+			if c := strings.Compare(num_a, num_b); c != 0 {
+				return c
+			}
+			a, b = rest_a, rest_b
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func isDigit(chr byte) bool {
+	return chr >= '0' && chr <= '9'
+}
+
+// digitPrefix splits s after its leading digits
+func digitPrefix(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// keyOrder returns the KeyOrder of a SortMode, nil for Random
+func (mode Sort) keyOrder() KeyOrder {
 	switch mode {
 	case DictAsc:
-		sort.Sort(ascending(ret))
+		return DictOrder
 	case DictDesc:
-		sort.Sort(descending(ret))
+		return func(a, b string) bool {
+			return a > b
+		}
 	default:
-		// do nothing
+		return nil
 	}
+}
+
+func sortObjects(obj *JsonValue, less KeyOrder) []*valuePair {
+	ret := make([]*valuePair, 0, obj.Length())
+	obj.ObjectForeach(func (k string, v *JsonValue) error {
+		ret = append(ret, &valuePair{K: k, V: v})
+		return nil
+	})
+	sort.Stable(byKey{ret, less})
 	return ret
 }
+
+// pathOrder is an entry of Option.PathKeyOrder
+type pathOrder struct {
+	text      string
+	path      []pathElem
+	wildcards int
+	order     KeyOrder
+}
+
+// marshalPath tracks the keys of the value being marshaled, for Option.PathKeyOrder
+type marshalPath struct {
+	orders []pathOrder
+	keys   []interface{}
+}
+
+// newMarshalPath returns nil if opt has no PathKeyOrder
+func newMarshalPath(opt *Option) (*marshalPath, error) {
+	if 0 == len(opt.PathKeyOrder) {
+		return nil, nil
+	}
+	mp := &marshalPath{}
+	for s, order := range opt.PathKeyOrder {
+		path, err := parsePath(s)
+		if err != nil {
+			return nil, err
+		}
+		wildcards := 0
+		for _, e := range path {
+			if e.kind == elemAnyKey || e.kind == elemAnyIndex {
+				wildcards++
+			}
+		}
+		mp.orders = append(mp.orders, pathOrder{s, path, wildcards, order})
+	}
+	// the path with the fewest wildcards wins, then the smallest path string, so that
+	// the result does not depend on the order of the map
+	sort.SliceStable(mp.orders, func(i, j int) bool {
+		a, b := mp.orders[i], mp.orders[j]
+		if a.wildcards != b.wildcards {
+			return a.wildcards < b.wildcards
+		}
+		return a.text < b.text
+	})
+	return mp, nil
+}
+
+func (mp *marshalPath) push(key interface{}) {
+	if mp != nil {
+		mp.keys = append(mp.keys, key)
+	}
+}
+
+func (mp *marshalPath) pop() {
+	if mp != nil {
+		mp.keys = mp.keys[:len(mp.keys)-1]
+	}
+}
+
+// keyOrder returns the KeyOrder configured for the object being marshaled, or nil
+func (mp *marshalPath) keyOrder() KeyOrder {
+	if nil == mp {
+		return nil
+	}
+	for _, o := range mp.orders {
+		if exact, _ := matchPath(o.path, mp.keys); exact {
+			return o.order
+		}
+	}
+	return nil
+}